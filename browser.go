@@ -0,0 +1,102 @@
+package emit
+
+import (
+	"github.com/bogdanfinn/tls-client/profiles"
+	"net/http"
+)
+
+// 浏览器请求头与 ja3 指纹的组合，User-Agent、sec-ch-ua 与 ClientProfile 的版本保持一致
+type BrowserProfile struct {
+	Name          string
+	ClientProfile profiles.ClientProfile
+	Headers       map[string]string
+	// 对应浏览器的 Accept-Encoding，作为 session 默认的 Encoding
+	Encodings []string
+}
+
+var (
+	ChromeProfile = BrowserProfile{
+		Name:          "chrome",
+		ClientProfile: profiles.Chrome_124,
+		Headers: map[string]string{
+			"User-Agent":         "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			"Sec-Ch-Ua":          `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`,
+			"Sec-Ch-Ua-Mobile":   "?0",
+			"Sec-Ch-Ua-Platform": `"Windows"`,
+			"Accept":             "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+			"Accept-Language":    "en-US,en;q=0.9",
+		},
+		Encodings: []string{"gzip", "deflate", "br", "zstd"},
+	}
+
+	// Edge 与 Chrome 同为 Chromium 内核，TLS 指纹一致
+	EdgeProfile = BrowserProfile{
+		Name:          "edge",
+		ClientProfile: profiles.Chrome_124,
+		Headers: map[string]string{
+			"User-Agent":         "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.0.0",
+			"Sec-Ch-Ua":          `"Chromium";v="124", "Microsoft Edge";v="124", "Not-A.Brand";v="99"`,
+			"Sec-Ch-Ua-Mobile":   "?0",
+			"Sec-Ch-Ua-Platform": `"Windows"`,
+			"Accept":             "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
+			"Accept-Language":    "en-US,en;q=0.9",
+		},
+		Encodings: []string{"gzip", "deflate", "br", "zstd"},
+	}
+
+	FirefoxProfile = BrowserProfile{
+		Name:          "firefox",
+		ClientProfile: profiles.Firefox_123,
+		Headers: map[string]string{
+			"User-Agent":      "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:123.0) Gecko/20100101 Firefox/123.0",
+			"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+			"Accept-Language": "en-US,en;q=0.5",
+		},
+		Encodings: []string{"gzip", "deflate", "br"},
+	}
+
+	SafariProfile = BrowserProfile{
+		Name:          "safari",
+		ClientProfile: profiles.Safari_16_0,
+		Headers: map[string]string{
+			"User-Agent":      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.0 Safari/605.1.15",
+			"Accept":          "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+			"Accept-Language": "en-US,en;q=0.9",
+		},
+		Encodings: []string{"gzip", "deflate", "br"},
+	}
+)
+
+// session 默认请求头，Builder / ConnBuilder 中同名（不区分大小写）的头优先
+func HeadersHelper(headers map[string]string) OptionHelper {
+	return func(_ string, _ bool, session *Session) error {
+		if session.headers == nil {
+			session.headers = make(map[string]string)
+		}
+		for k, v := range headers {
+			session.headers[http.CanonicalHeaderKey(k)] = v
+		}
+		return nil
+	}
+}
+
+// 使用浏览器的请求头、Accept-Encoding 以及对应的 ja3 指纹
+func BrowserHelper(profile BrowserProfile, timeout int) OptionHelper {
+	ja3 := Ja3Helper(Echo{HelloID: profile.ClientProfile}, timeout)
+	headers := HeadersHelper(profile.Headers)
+	return func(proxies string, redirect bool, session *Session) error {
+		if err := ja3(proxies, redirect, session); err != nil {
+			return err
+		}
+		session.encoding = profile.Encodings
+		return headers(proxies, redirect, session)
+	}
+}
+
+// Builder 未设置 Encoding 时使用 session 的默认值
+func (c *Builder) encodings() []string {
+	if len(c.encoding) == 0 && c.session != nil {
+		return c.session.encoding
+	}
+	return c.encoding
+}
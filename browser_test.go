@@ -0,0 +1,101 @@
+package emit
+
+import (
+	"encoding/json"
+	"github.com/RomiChan/websocket"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestBrowserProfile(t *testing.T) {
+	versions := map[string]*regexp.Regexp{
+		"Chrome":  regexp.MustCompile(`Chrome/(\d+)\.`),
+		"Firefox": regexp.MustCompile(`Firefox/(\d+)\.`),
+		"Safari":  regexp.MustCompile(`Version/([\d.]+) `),
+	}
+
+	for _, profile := range []BrowserProfile{ChromeProfile, EdgeProfile, FirefoxProfile, SafariProfile} {
+		id := profile.ClientProfile.GetClientHelloId()
+		matches := versions[id.Client].FindStringSubmatch(profile.Headers["User-Agent"])
+		if len(matches) < 2 || matches[1] != id.Version {
+			t.Fatalf("%s: User-Agent does not match %s", profile.Name, profile.ClientProfile.GetClientHelloStr())
+		}
+
+		if ua, ok := profile.Headers["Sec-Ch-Ua"]; ok && !strings.Contains(ua, `v="`+id.Version+`"`) {
+			t.Fatalf("%s: sec-ch-ua does not match %s", profile.Name, profile.ClientProfile.GetClientHelloStr())
+		}
+	}
+}
+
+func TestSessionHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if websocket.IsWebSocketUpgrade(r) {
+			upgrader := websocket.Upgrader{}
+			c, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			_ = c.WriteJSON(r.Header)
+			_ = c.Close()
+			return
+		}
+
+		data, _ := json.Marshal(r.Header)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "zstd") {
+			w.Header().Set("Content-Encoding", "zstd")
+			data = compressText(t, data, "zstd")
+		}
+		_, _ = w.Write(data)
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil,
+		BrowserHelper(ChromeProfile, 30),
+		HeadersHelper(map[string]string{"origin": "https://example.com"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).
+			GET(server.URL).
+			Header("accept-language", "zh-CN")
+		if ja3 {
+			builder.Ja3()
+		}
+
+		header, err := DoJSON[http.Header](builder, Status(http.StatusOK))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if header.Get("User-Agent") != ChromeProfile.Headers["User-Agent"] ||
+			header.Get("Sec-Ch-Ua-Platform") != `"Windows"` ||
+			header.Get("Origin") != "https://example.com" ||
+			header.Get("Accept-Encoding") != "gzip, deflate, br, zstd" ||
+			len(header.Values("Accept-Language")) != 1 || header.Get("Accept-Language") != "zh-CN" {
+			t.Fatalf("ja3=%v unexpected headers: %v", ja3, header)
+		}
+	}
+
+	c, _, err := SocketBuilder(session).
+		URL(strings.Replace(server.URL, "http", "ws", 1)).
+		Header("Origin", server.URL).
+		DoS(http.StatusSwitchingProtocols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var header http.Header
+	if err = c.ReadJSON(&header); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("User-Agent") != ChromeProfile.Headers["User-Agent"] || header.Get("Origin") != server.URL {
+		t.Fatalf("unexpected websocket headers: %v", header)
+	}
+}
@@ -0,0 +1,72 @@
+package emit
+
+import (
+	"bufio"
+	"bytes"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// 流式转码为 UTF-8 的 body，不会关闭 body。
+// 编码依次取自 BOM、Content-Type 的 charset、HTML 的 meta 标签；
+// 非 HTML 且无法确定编码时原样返回
+func TextReader(response *http.Response) (io.Reader, error) {
+	if response == nil || response.Body == nil {
+		return strings.NewReader(""), nil
+	}
+
+	contentType := response.Header.Get("Content-Type")
+	br := bufio.NewReaderSize(response.Body, 1024)
+	preview, err := br.Peek(1024)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, Error{-1, "Text", "", err}
+	}
+
+	e, name := Charset(preview, contentType)
+	if e == nil || e == encoding.Nop {
+		return br, nil
+	}
+
+	if name == "utf-8" {
+		if bytes.HasPrefix(preview, []byte("\xEF\xBB\xBF")) {
+			_, _ = br.Discard(3)
+		}
+		return br, nil
+	}
+	return transform.NewReader(br, unicode.BOMOverride(e.NewDecoder())), nil
+}
+
+// 读取全部内容并转码为 UTF-8
+func DecodeText(response *http.Response) (string, error) {
+	reader, err := TextReader(response)
+	if err != nil {
+		return "", err
+	}
+
+	bin, err := io.ReadAll(reader)
+	if err != nil {
+		return string(bin), Error{-1, "Text", "", err}
+	}
+	return string(bin), nil
+}
+
+// 探测 preview 的编码，无法确定时返回 nil
+func Charset(preview []byte, contentType string) (encoding.Encoding, string) {
+	e, name, certain := charset.DetermineEncoding(preview, contentType)
+	if certain {
+		return e, name
+	}
+
+	// 只有 HTML 才信任 meta 标签以及按浏览器规则回退
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "text/html" || mediaType == "application/xhtml+xml" {
+		return e, name
+	}
+	return nil, ""
+}
@@ -0,0 +1,78 @@
+package emit
+
+import (
+	"bytes"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func textResponse(contentType string, body []byte) *http.Response {
+	return &http.Response{
+		Header: http.Header{"Content-Type": {contentType}},
+		Body:   io.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+func TestTextResponse(t *testing.T) {
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String("你好，世界")
+	sjis, _ := japanese.ShiftJIS.NewEncoder().String("こんにちは")
+	latin1, _ := charmap.ISO8859_1.NewEncoder().String("café")
+
+	for _, c := range []struct {
+		name        string
+		contentType string
+		body        string
+		expected    string
+	}{
+		{"header", "text/plain; charset=GBK", gbk, "你好，世界"},
+		{"meta", "text/html", `<html><head><meta charset="shift_jis"></head><body>` + sjis + `</body></html>`,
+			`<html><head><meta charset="shift_jis"></head><body>こんにちは</body></html>`},
+		{"http-equiv", "text/html", `<meta http-equiv="Content-Type" content="text/html; charset=gb2312">` + gbk,
+			`<meta http-equiv="Content-Type" content="text/html; charset=gb2312">你好，世界`},
+		{"latin1", "text/plain; charset=iso-8859-1", latin1, "café"},
+		{"utf-8 bom", "text/plain", "\xEF\xBB\xBFhello", "hello"},
+		{"utf-16 bom", "text/plain", "\xFF\xFEh\x00i\x00", "hi"},
+		{"utf-8", "application/json", `{"a":"你好"}`, `{"a":"你好"}`},
+		// 非 HTML 且未声明编码的内容保持原样
+		{"unknown", "application/octet-stream", "\xC4\xE3", "\xC4\xE3"},
+	} {
+		if value := TextResponse(textResponse(c.contentType, []byte(c.body))); value != c.expected {
+			t.Fatalf("%s: unexpected text: %q", c.name, value)
+		}
+	}
+}
+
+func TestTextReader(t *testing.T) {
+	gbk, _ := simplifiedchinese.GBK.NewEncoder().String(strings.Repeat("流式转码", 4096))
+	reader, err := TextReader(textResponse("text/plain; charset=gbk", []byte(gbk)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 7)
+	var out strings.Builder
+	for {
+		n, err := reader.Read(buf)
+		out.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if out.String() != strings.Repeat("流式转码", 4096) {
+		t.Fatalf("unexpected text length: %d", out.Len())
+	}
+
+	value, err := DecodeText(textResponse("text/html", nil))
+	if err != nil || value != "" {
+		t.Fatalf("unexpected empty text: %q %v", value, err)
+	}
+}
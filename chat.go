@@ -0,0 +1,158 @@
+package emit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type ChatChunk struct {
+	// Anthropic 的事件名（message_start、content_block_delta ...），OpenAI 为空
+	Event string
+	Data  json.RawMessage
+	// 本次的文本增量
+	Text string
+}
+
+// OpenAI / Anthropic 风格的流式响应：以 data: [DONE] 或 message_stop 结束，
+// 流中的 error 事件以 Error 返回
+type ChatStream struct {
+	reader *SSEReader
+	body   io.Closer
+	text   strings.Builder
+	done   bool
+}
+
+type chatPayload struct {
+	Type    string `json:"type"`
+	Choices []struct {
+		Text  string `json:"text"`
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Error *struct {
+		Type    string      `json:"type"`
+		Code    interface{} `json:"code"`
+		Message string      `json:"message"`
+	} `json:"error"`
+}
+
+func NewChatStream(response *http.Response) *ChatStream {
+	return &ChatStream{
+		reader: NewSSEReader(response.Body),
+		body:   response.Body,
+	}
+}
+
+// 读取下一个数据块，流结束后返回 io.EOF
+func (s *ChatStream) Next() (*ChatChunk, error) {
+	for {
+		if s.done {
+			return nil, io.EOF
+		}
+
+		event, err := s.reader.Next()
+		if err != nil {
+			if err == io.EOF {
+				s.done = true
+			}
+			return nil, err
+		}
+
+		data := strings.TrimSpace(event.Data)
+		if data == "" {
+			continue
+		}
+
+		if data == "[DONE]" {
+			s.done = true
+			return nil, io.EOF
+		}
+
+		var payload chatPayload
+		if err = json.Unmarshal([]byte(data), &payload); err != nil {
+			return nil, Error{-1, "Chat", data, err}
+		}
+
+		name := event.Event
+		if name == "" || name == "message" {
+			name = payload.Type
+		}
+
+		if payload.Error != nil || name == "error" {
+			s.done = true
+			return nil, chatError(payload, data)
+		}
+
+		chunk := &ChatChunk{Event: name, Data: json.RawMessage(data)}
+		switch {
+		case payload.Delta.Type == "text_delta":
+			chunk.Text = payload.Delta.Text
+		case len(payload.Choices) > 0:
+			chunk.Text = payload.Choices[0].Delta.Content
+			if chunk.Text == "" {
+				chunk.Text = payload.Choices[0].Text
+			}
+		}
+		s.text.WriteString(chunk.Text)
+
+		if name == "message_stop" {
+			s.done = true
+		}
+		return chunk, nil
+	}
+}
+
+// 已累积的文本
+func (s *ChatStream) Text() string {
+	return s.text.String()
+}
+
+func (s *ChatStream) Close() error {
+	return s.body.Close()
+}
+
+// 逐块回调，回调返回false时停止；返回累积的文本，结束后关闭 body
+func ReadChat(response *http.Response, funcCall func(chunk ChatChunk) bool) (string, error) {
+	s := NewChatStream(response)
+	defer s.Close()
+	for {
+		chunk, err := s.Next()
+		if err == io.EOF {
+			return s.Text(), nil
+		}
+		if err != nil {
+			return s.Text(), err
+		}
+		if funcCall != nil && !funcCall(*chunk) {
+			return s.Text(), nil
+		}
+	}
+}
+
+func (chunk ChatChunk) Unmarshal(v interface{}) error {
+	return json.Unmarshal(chunk.Data, v)
+}
+
+func chatError(payload chatPayload, data string) error {
+	if payload.Error == nil {
+		return Error{-1, "Chat", data, errors.New("stream error")}
+	}
+
+	typ := payload.Error.Type
+	if typ == "" && payload.Error.Code != nil {
+		typ = fmt.Sprint(payload.Error.Code)
+	}
+	if typ == "" {
+		typ = "stream error"
+	}
+	return Error{-1, "Chat", payload.Error.Message, errors.New(typ)}
+}
@@ -0,0 +1,122 @@
+package emit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newChatServer(stream string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(stream))
+	}))
+}
+
+func TestChatOpenAI(t *testing.T) {
+	server := newChatServer(
+		"data: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":null}}]}\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n" +
+			": keep-alive\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\", world\"}}]}\n\n" +
+			"data: [DONE]\n\n" +
+			"data: {\"choices\":[{\"delta\":{\"content\":\"ignored\"}}]}\n\n")
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).GET(server.URL)
+		if ja3 {
+			builder.Ja3()
+		}
+
+		response, err := builder.DoC(Status(http.StatusOK), IsSTREAM)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		count := 0
+		text, err := ReadChat(response, func(chunk ChatChunk) bool {
+			count++
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text != "Hello, world" || count != 3 {
+			t.Fatalf("ja3=%v unexpected text: %q (%d chunks)", ja3, text, count)
+		}
+	}
+}
+
+func TestChatAnthropic(t *testing.T) {
+	stream := NewChatStream(&http.Response{Body: io.NopCloser(strings.NewReader(
+		"event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\"}}\n\n" +
+			"event: content_block_start\ndata: {\"type\":\"content_block_start\",\"index\":0}\n\n" +
+			"event: ping\ndata: {\"type\":\"ping\"}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"Hi\"}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"{}\"}}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\" there\"}}\n\n" +
+			"event: message_delta\ndata: {\"type\":\"message_delta\",\"delta\":{\"stop_reason\":\"end_turn\"}}\n\n" +
+			"event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n" +
+			"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"!\"}}\n\n"))})
+	defer stream.Close()
+
+	var events []string
+	for {
+		chunk, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, chunk.Event)
+		if chunk.Event == "message_start" {
+			var start struct {
+				Message struct {
+					Id string `json:"id"`
+				} `json:"message"`
+			}
+			if err = chunk.Unmarshal(&start); err != nil || start.Message.Id != "msg_1" {
+				t.Fatalf("unexpected message_start: %s", chunk.Data)
+			}
+		}
+	}
+
+	if stream.Text() != "Hi there" {
+		t.Fatalf("unexpected text: %q", stream.Text())
+	}
+	if len(events) != 8 || events[len(events)-1] != "message_stop" {
+		t.Fatalf("unexpected events: %v", events)
+	}
+}
+
+func TestChatError(t *testing.T) {
+	for _, stream := range []string{
+		"event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"a\"}}\n\n" +
+			"event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n",
+		"data: {\"choices\":[{\"delta\":{\"content\":\"a\"}}]}\n\n" +
+			"data: {\"error\":{\"message\":\"Overloaded\",\"type\":\"overloaded_error\"}}\n\n",
+	} {
+		text, err := ReadChat(&http.Response{Body: io.NopCloser(strings.NewReader(stream))}, nil)
+
+		var e Error
+		if !errors.As(err, &e) {
+			t.Fatalf("expected Error, got %v", err)
+		}
+		if e.Bus != "Chat" || e.Msg != "Overloaded" || e.Err.Error() != "overloaded_error" {
+			t.Fatalf("unexpected error: %+v", e)
+		}
+		if text != "a" {
+			t.Fatalf("unexpected text: %q", text)
+		}
+	}
+}
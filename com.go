@@ -1,9 +1,6 @@
 package emit
 
 import (
-	"bytes"
-	"compress/gzip"
-	"io"
 	"math/rand"
 	"strconv"
 	"strings"
@@ -75,35 +72,22 @@ var _range = [][]string{
 	{"20.73.0.0", "20.73.255.255"},       // Azure Cloud WestEurope 65534
 }
 
+// 根据魔数判断数据是否为对应的编码；deflate 无法区分 raw 格式，br 没有魔数，均视为已编码
 func IsEncoding(data []byte, encoding string) bool {
 	switch encoding {
 	case "gzip":
 		return len(data) >= 2 &&
 			data[0] == 0x1F &&
 			data[1] == 0x8B
-	case "deflate":
-		// ？？？
+	case "zstd":
+		return len(data) >= 4 &&
+			data[0] == 0x28 &&
+			data[1] == 0xB5 &&
+			data[2] == 0x2F &&
+			data[3] == 0xFD
+	case "deflate", "br":
 		return true
-	case "br":
-		return len(data) >= 3 &&
-			data[0] == 0xCE &&
-			data[1] == 0xB2 &&
-			data[2] == 0xCF
 	default:
 		return false
 	}
 }
-
-func DecodeGZip(closer io.ReadCloser) (io.ReadCloser, error) {
-	if closer == nil {
-		return closer, nil
-	}
-
-	buf := new(bytes.Buffer)
-	_, err := io.Copy(buf, closer)
-	if err != nil {
-		return nil, err
-	}
-
-	return gzip.NewReader(buf)
-}
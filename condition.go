@@ -23,10 +23,19 @@ func IsSTREAM(response *http.Response) error {
 	return ist(response, "Stream", "text/event-stream", "application/stream")
 }
 
+func IsEVENTSTREAM(response *http.Response) error {
+	return ist(response, "EventStream", "application/vnd.amazon.eventstream")
+}
+
 func IsPROTO(response *http.Response) error {
 	return ist(response, "Proto", "application/connect+proto", "application/proto")
 }
 
+// Connect、gRPC-web 的分帧响应，不区分编码
+func IsRPC(response *http.Response) error {
+	return ist(response, "RPC", "application/connect+", "application/grpc-web")
+}
+
 func Status(status int) func(response *http.Response) error {
 	return func(response *http.Response) error {
 		if response == nil {
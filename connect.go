@@ -0,0 +1,296 @@
+package emit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// Connect / gRPC-web 的帧标志：1字节 flags + 4字节大端长度 + 消息体
+const (
+	EnvelopeCompressed byte = 0x01
+	EnvelopeEndStream  byte = 0x02
+	EnvelopeTrailer    byte = 0x80
+)
+
+// 单帧默认最大长度
+const RPCMaxMessageSize = 4 * 1024 * 1024
+
+// 消息的编解码，Name 用于 Content-Type（application/connect+{Name}）。
+// protobuf 消息可自行传入 proto.Marshal / proto.Unmarshal 的包装
+type RPCCodec struct {
+	Name      string
+	Marshal   func(v interface{}) ([]byte, error)
+	Unmarshal func(data []byte, v interface{}) error
+	// 请求帧的压缩方式，目前只支持 gzip，为空不压缩
+	Compression string
+}
+
+var RPCJSONCodec = RPCCodec{Name: "json", Marshal: json.Marshal, Unmarshal: json.Unmarshal}
+
+type Envelope struct {
+	Flags byte
+	Data  []byte
+}
+
+// 流式读取 Connect / gRPC-web 响应，按 Content-Type 区分协议
+type RPCStream struct {
+	response *http.Response
+	reader   *bufio.Reader
+	codec    RPCCodec
+	grpc     bool
+	encoding string
+	maxSize  int
+	trailer  http.Header
+	done     bool
+}
+
+// Connect 流式请求：messages 逐个编码为帧作为请求体
+func (c *Builder) Connect(codec RPCCodec, messages ...interface{}) *Builder {
+	c.Header("Content-Type", "application/connect+"+codec.Name).
+		Header("Connect-Protocol-Version", "1")
+	if codec.Compression != "" {
+		c.Header("Connect-Content-Encoding", codec.Compression).
+			Header("Connect-Accept-Encoding", codec.Compression)
+	}
+	return c.envelopes("Connect", codec, messages)
+}
+
+// gRPC-web 请求：messages 逐个编码为帧作为请求体
+func (c *Builder) GrpcWeb(codec RPCCodec, messages ...interface{}) *Builder {
+	c.Header("Content-Type", "application/grpc-web+"+codec.Name).
+		Header("X-Grpc-Web", "1")
+	if codec.Compression != "" {
+		c.Header("Grpc-Encoding", codec.Compression).
+			Header("Grpc-Accept-Encoding", codec.Compression)
+	}
+	return c.envelopes("GrpcWeb", codec, messages)
+}
+
+func (c *Builder) envelopes(bus string, codec RPCCodec, messages []interface{}) *Builder {
+	if c.err != nil {
+		return c
+	}
+
+	var buf bytes.Buffer
+	for _, message := range messages {
+		data, err := codec.Marshal(message)
+		if err != nil {
+			c.err = Error{-1, bus, "", err}
+			return c
+		}
+
+		var flags byte
+		if codec.Compression != "" {
+			if data, err = compressRPC(codec.Compression, data); err != nil {
+				c.err = Error{-1, bus, "", err}
+				return c
+			}
+			flags |= EnvelopeCompressed
+		}
+		buf.Write(EncodeEnvelope(flags, data))
+	}
+	c.bytes, c.buffer = buf.Bytes(), nil
+	return c
+}
+
+func EncodeEnvelope(flags byte, data []byte) []byte {
+	frame := make([]byte, 5+len(data))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(data)))
+	copy(frame[5:], data)
+	return frame
+}
+
+// 读取一帧，流在帧边界结束时返回 io.EOF，帧不完整返回 io.ErrUnexpectedEOF
+func ReadEnvelope(reader io.Reader, maxSize int) (*Envelope, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(reader, prefix[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(prefix[1:5])
+	if maxSize > 0 && int64(size) > int64(maxSize) {
+		return nil, fmt.Errorf("envelope size %d exceeds limit %d", size, maxSize)
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return &Envelope{prefix[0], data}, nil
+}
+
+func NewRPCStream(response *http.Response, codec RPCCodec) *RPCStream {
+	grpc := strings.Contains(response.Header.Get("Content-Type"), "application/grpc-web")
+	encoding := response.Header.Get("Connect-Content-Encoding")
+	if grpc {
+		encoding = response.Header.Get("Grpc-Encoding")
+	}
+
+	return &RPCStream{
+		response: response,
+		reader:   bufio.NewReader(response.Body),
+		codec:    codec,
+		grpc:     grpc,
+		encoding: encoding,
+		maxSize:  RPCMaxMessageSize,
+		trailer:  http.Header{},
+	}
+}
+
+func (s *RPCStream) MaxMessageSize(size int) *RPCStream {
+	s.maxSize = size
+	return s
+}
+
+// 解码下一条消息到 v；正常结束返回 io.EOF，结束帧中的错误以 Error 返回
+func (s *RPCStream) Next(v interface{}) error {
+	if s.done {
+		return io.EOF
+	}
+
+	envelope, err := ReadEnvelope(s.reader, s.maxSize)
+	if err == io.EOF {
+		s.done = true
+		return s.finish(nil)
+	}
+	if err != nil {
+		s.done = true
+		return Error{-1, s.bus(), "", err}
+	}
+
+	data := envelope.Data
+	if envelope.Flags&EnvelopeCompressed != 0 {
+		if data, err = decompressRPC(s.encoding, data); err != nil {
+			s.done = true
+			return Error{-1, s.bus(), "", err}
+		}
+	}
+
+	if envelope.Flags&(EnvelopeEndStream|EnvelopeTrailer) != 0 {
+		s.done = true
+		return s.finish(data)
+	}
+
+	if err = s.codec.Unmarshal(data, v); err != nil {
+		return Error{-1, s.bus(), "", err}
+	}
+	return nil
+}
+
+// 结束帧中的 metadata / grpc trailers
+func (s *RPCStream) Trailer() http.Header {
+	return s.trailer
+}
+
+func (s *RPCStream) Close() error {
+	return s.response.Body.Close()
+}
+
+func (s *RPCStream) bus() string {
+	if s.grpc {
+		return "GrpcWeb"
+	}
+	return "Connect"
+}
+
+func (s *RPCStream) finish(data []byte) error {
+	if s.grpc {
+		return s.finishGrpc(data)
+	}
+
+	if data == nil {
+		return Error{-1, "Connect", "", errors.New("missing end-stream message")}
+	}
+
+	var end struct {
+		Error *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+		Metadata map[string][]string `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &end); err != nil {
+		return Error{-1, "Connect", string(data), err}
+	}
+
+	for k, values := range end.Metadata {
+		for _, value := range values {
+			s.trailer.Add(k, value)
+		}
+	}
+
+	if end.Error != nil {
+		return Error{-1, "Connect", end.Error.Message, errors.New(end.Error.Code)}
+	}
+	return io.EOF
+}
+
+func (s *RPCStream) finishGrpc(data []byte) error {
+	if data != nil {
+		reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(data, '\r', '\n'))))
+		header, err := reader.ReadMIMEHeader()
+		if err != nil && err != io.EOF {
+			return Error{-1, "GrpcWeb", string(data), err}
+		}
+		for k, values := range header {
+			s.trailer[k] = values
+		}
+	} else if s.response.Header.Get("Grpc-Status") != "" {
+		// trailers-only 响应
+		for _, k := range []string{"Grpc-Status", "Grpc-Message"} {
+			if value := s.response.Header.Get(k); value != "" {
+				s.trailer.Set(k, value)
+			}
+		}
+	} else {
+		return Error{-1, "GrpcWeb", "", errors.New("missing grpc trailers")}
+	}
+
+	status := s.trailer.Get("Grpc-Status")
+	if status != "0" {
+		return Error{-1, "GrpcWeb", s.trailer.Get("Grpc-Message"), fmt.Errorf("grpc-status %s", status)}
+	}
+	return io.EOF
+}
+
+func compressRPC(encoding string, data []byte) ([]byte, error) {
+	if encoding != "gzip" {
+		return nil, fmt.Errorf("unsupported compression: %s", encoding)
+	}
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompressRPC(encoding string, data []byte) ([]byte, error) {
+	if encoding != "gzip" {
+		return nil, fmt.Errorf("unsupported compression: %q", encoding)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
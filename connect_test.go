@@ -0,0 +1,187 @@
+package emit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type rpcMessage struct {
+	Text string `json:"text"`
+}
+
+func newRPCServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		grpc := strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc-web")
+		encoding := r.Header.Get("Connect-Content-Encoding")
+		if grpc {
+			encoding = r.Header.Get("Grpc-Encoding")
+		}
+
+		var texts []string
+		for {
+			envelope, err := ReadEnvelope(r.Body, 0)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			data := envelope.Data
+			if envelope.Flags&EnvelopeCompressed != 0 {
+				if data, err = decompressRPC(encoding, data); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+
+			var message rpcMessage
+			if err = RPCJSONCodec.Unmarshal(data, &message); err != nil {
+				t.Error(err)
+				return
+			}
+			texts = append(texts, message.Text)
+		}
+
+		failed := r.URL.Query().Get("fail") != ""
+		if grpc {
+			w.Header().Set("Content-Type", "application/grpc-web+json")
+			w.Header().Set("Grpc-Encoding", "gzip")
+		} else {
+			w.Header().Set("Content-Type", "application/connect+json")
+			w.Header().Set("Connect-Content-Encoding", "gzip")
+		}
+
+		for i, text := range texts {
+			data, _ := RPCJSONCodec.Marshal(rpcMessage{Text: strings.ToUpper(text)})
+			var flags byte
+			if i%2 == 1 {
+				data, _ = compressRPC("gzip", data)
+				flags = EnvelopeCompressed
+			}
+			_, _ = w.Write(EncodeEnvelope(flags, data))
+			w.(http.Flusher).Flush()
+		}
+
+		switch {
+		case grpc && failed:
+			_, _ = w.Write(EncodeEnvelope(EnvelopeTrailer, []byte("grpc-status: 5\r\ngrpc-message: not found\r\n")))
+		case grpc:
+			_, _ = w.Write(EncodeEnvelope(EnvelopeTrailer, []byte("grpc-status: 0\r\nx-count: "+fmt.Sprint(len(texts))+"\r\n")))
+		case failed:
+			_, _ = w.Write(EncodeEnvelope(EnvelopeEndStream, []byte(`{"error":{"code":"not_found","message":"not found"}}`)))
+		default:
+			_, _ = w.Write(EncodeEnvelope(EnvelopeEndStream, []byte(fmt.Sprintf(`{"metadata":{"x-count":["%d"]}}`, len(texts)))))
+		}
+	}))
+}
+
+func TestRPCStream(t *testing.T) {
+	server := newRPCServer(t)
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	codec := RPCJSONCodec
+	codec.Compression = "gzip"
+
+	for _, grpc := range []bool{false, true} {
+		for _, ja3 := range []bool{false, true} {
+			builder := ClientBuilder(session).POST(server.URL)
+			if grpc {
+				builder.GrpcWeb(codec, rpcMessage{"a"}, rpcMessage{"b"}, rpcMessage{"c"})
+			} else {
+				builder.Connect(codec, rpcMessage{"a"}, rpcMessage{"b"}, rpcMessage{"c"})
+			}
+			if ja3 {
+				builder.Ja3()
+			}
+
+			response, err := builder.DoC(Status(http.StatusOK), IsRPC)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stream := NewRPCStream(response, RPCJSONCodec)
+			var texts []string
+			for {
+				var message rpcMessage
+				err = stream.Next(&message)
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("grpc=%v ja3=%v: %v", grpc, ja3, err)
+				}
+				texts = append(texts, message.Text)
+			}
+			_ = stream.Close()
+
+			if strings.Join(texts, ",") != "A,B,C" {
+				t.Fatalf("grpc=%v ja3=%v unexpected messages: %v", grpc, ja3, texts)
+			}
+			if stream.Trailer().Get("X-Count") != "3" {
+				t.Fatalf("grpc=%v ja3=%v unexpected trailer: %v", grpc, ja3, stream.Trailer())
+			}
+		}
+	}
+}
+
+func TestRPCStreamError(t *testing.T) {
+	server := newRPCServer(t)
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, grpc := range []bool{false, true} {
+		for _, ja3 := range []bool{false, true} {
+			builder := ClientBuilder(session).POST(server.URL).Query("fail", "1")
+			if grpc {
+				builder.GrpcWeb(RPCJSONCodec, rpcMessage{"a"})
+			} else {
+				builder.Connect(RPCJSONCodec, rpcMessage{"a"})
+			}
+			if ja3 {
+				builder.Ja3()
+			}
+
+			response, err := builder.DoC(Status(http.StatusOK), IsRPC)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stream := NewRPCStream(response, RPCJSONCodec)
+			var message rpcMessage
+			if err = stream.Next(&message); err != nil || message.Text != "A" {
+				t.Fatalf("grpc=%v ja3=%v unexpected message: %v %v", grpc, ja3, message, err)
+			}
+
+			var e Error
+			if err = stream.Next(&message); !errors.As(err, &e) || e.Msg != "not found" {
+				t.Fatalf("grpc=%v ja3=%v expected Error, got %v", grpc, ja3, err)
+			}
+			if err = stream.Next(&message); err != io.EOF {
+				t.Fatalf("grpc=%v ja3=%v expected io.EOF after end, got %v", grpc, ja3, err)
+			}
+			_ = stream.Close()
+		}
+	}
+
+	// IsPROTO 只接受 protobuf 编码
+	response := &http.Response{Header: http.Header{"Content-Type": {"application/connect+json"}}, Body: http.NoBody}
+	if IsPROTO(response) == nil || IsRPC(response) != nil {
+		t.Fatal("unexpected content type match")
+	}
+}
@@ -0,0 +1,162 @@
+package emit
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// 支持的 Content-Encoding
+var Encodings = []string{"gzip", "deflate", "br", "zstd"}
+
+// 按 Content-Encoding 由外到内逐层流式解压，accepts 为空时解压所有支持的编码；
+// 遇到不接受或不支持的编码时停止，剩余的编码保留在 Content-Encoding 中。
+// 解压器在第一次读取时才初始化，关闭 body 会同时释放解压器和原始连接
+func DecodeBody(response *http.Response, accepts ...string) {
+	if response == nil || response.Body == nil {
+		return
+	}
+
+	var encodings []string
+	for _, value := range response.Header.Values("Content-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			encoding = strings.ToLower(strings.TrimSpace(encoding))
+			if encoding != "" && encoding != "identity" {
+				encodings = append(encodings, encoding)
+			}
+		}
+	}
+
+	decoded := 0
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := encodings[i]
+		if !slices.Contains(Encodings, encoding) ||
+			(len(accepts) > 0 && !slices.Contains(accepts, encoding)) {
+			break
+		}
+		response.Body = &decodeReader{encoding: encoding, body: response.Body}
+		decoded++
+	}
+
+	if decoded == 0 {
+		return
+	}
+
+	response.Header.Del("Content-Length")
+	response.ContentLength = -1
+	if remain := encodings[:len(encodings)-decoded]; len(remain) > 0 {
+		response.Header.Set("Content-Encoding", strings.Join(remain, ", "))
+	} else {
+		response.Header.Del("Content-Encoding")
+		response.Uncompressed = true
+	}
+}
+
+type decodeReader struct {
+	encoding string
+	body     io.ReadCloser
+	reader   io.Reader
+	release  func()
+	err      error
+}
+
+func (d *decodeReader) Read(p []byte) (int, error) {
+	if d.reader == nil && d.err == nil {
+		d.reader, d.release, d.err = newDecoder(d.encoding, d.body)
+		if d.err != nil && d.err != io.EOF {
+			d.err = Error{-1, "Decode " + d.encoding, "", d.err}
+		}
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.reader.Read(p)
+}
+
+func (d *decodeReader) Close() error {
+	if d.release != nil {
+		d.release()
+	}
+	return d.body.Close()
+}
+
+// 空 body 时返回 io.EOF；gzip、zstd 先检查魔数，已被解压过的数据原样返回
+func newDecoder(encoding string, body io.Reader) (io.Reader, func(), error) {
+	if encoding == "gzip" || encoding == "zstd" {
+		br := bufio.NewReader(body)
+		header, err := br.Peek(4)
+		if len(header) == 0 && err != nil {
+			return nil, nil, err
+		}
+		if !IsEncoding(header, encoding) {
+			return br, nil, nil
+		}
+		body = br
+	}
+
+	switch encoding {
+	case "gzip":
+		r, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, func() { _ = r.Close() }, nil
+
+	case "deflate":
+		// HTTP 的 deflate 应为 zlib 格式，但不少服务端直接返回 raw deflate
+		br := bufio.NewReader(body)
+		header, err := br.Peek(2)
+		if len(header) == 0 && err != nil {
+			return nil, nil, err
+		}
+		if isZlib(header) {
+			r, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, nil, err
+			}
+			return r, func() { _ = r.Close() }, nil
+		}
+		r := flate.NewReader(br)
+		return r, func() { _ = r.Close() }, nil
+
+	case "br":
+		return brotli.NewReader(body), nil, nil
+
+	case "zstd":
+		r, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, r.Close, nil
+
+	default:
+		return nil, nil, fmt.Errorf("unsupported encoding: %s", encoding)
+	}
+}
+
+func isZlib(header []byte) bool {
+	return len(header) >= 2 &&
+		header[0]&0x0F == 8 &&
+		(uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
+// 流式解压 gzip，关闭时同时关闭原始 body
+func DecodeGZip(closer io.ReadCloser) (io.ReadCloser, error) {
+	if closer == nil {
+		return closer, nil
+	}
+
+	r, err := gzip.NewReader(closer)
+	if err != nil {
+		return nil, err
+	}
+	return &decodeReader{encoding: "gzip", body: closer, reader: r, release: func() { _ = r.Close() }}, nil
+}
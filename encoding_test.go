@@ -0,0 +1,201 @@
+package emit
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/andybalholm/brotli"
+	"github.com/bogdanfinn/tls-client"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+var encodingText = strings.Repeat("emit.io streaming decompression ", 64)
+
+func compressText(t *testing.T, data []byte, encoding string) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	case "zstd":
+		w, _ = zstd.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown encoding: %s", encoding)
+	}
+	if _, err := w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func newEncodingServer(t *testing.T, accept *atomic.Value) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept.Store(r.Header.Get("Accept-Encoding"))
+
+		encodings := strings.Split(r.URL.Query().Get("e"), ",")
+		data := []byte(encodingText)
+		var names []string
+		for _, encoding := range encodings {
+			data = compressText(t, data, encoding)
+			names = append(names, strings.TrimPrefix(encoding, "raw-"))
+		}
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Encoding", strings.Join(names, ", "))
+		_, _ = w.Write(data)
+	}))
+}
+
+func TestDecodeBody(t *testing.T) {
+	var accept atomic.Value
+	server := newEncodingServer(t, &accept)
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate", "br", "zstd", "gzip,br", "zstd,deflate,gzip"} {
+		response, err := ClientBuilder(session).
+			GET(server.URL).
+			Query("e", encoding).
+			Encoding(Encodings...).
+			DoS(http.StatusOK)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if value := TextResponse(response); value != encodingText {
+			t.Fatalf("%s: unexpected body: %.64q", encoding, value)
+		}
+		_ = response.Body.Close()
+
+		if response.Header.Get("Content-Encoding") != "" || !response.Uncompressed {
+			t.Fatalf("%s: unexpected header: %v", encoding, response.Header)
+		}
+		if accept.Load() != "gzip, deflate, br, zstd" {
+			t.Fatalf("%s: unexpected Accept-Encoding: %v", encoding, accept.Load())
+		}
+	}
+
+	// 只接受 br 时，外层的 gzip 不解压
+	response, err := ClientBuilder(session).
+		GET(server.URL).
+		Query("e", "br,gzip").
+		Encoding("br").
+		DoS(http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.Header.Get("Content-Encoding") != "br, gzip" || response.Uncompressed {
+		t.Fatalf("unexpected header: %v", response.Header)
+	}
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestDecodeBodyClose(t *testing.T) {
+	body := &closeRecorder{Reader: bytes.NewReader(compressText(t, compressText(t, []byte(encodingText), "zstd"), "gzip"))}
+	response := &http.Response{
+		Header:        http.Header{"Content-Encoding": {"zstd, gzip"}, "Content-Length": {"1"}},
+		Body:          body,
+		ContentLength: 1,
+	}
+
+	DecodeBody(response)
+	if response.ContentLength != -1 || response.Header.Get("Content-Length") != "" {
+		t.Fatalf("unexpected length: %d", response.ContentLength)
+	}
+
+	data, err := io.ReadAll(response.Body)
+	if err != nil || string(data) != encodingText {
+		t.Fatalf("unexpected body: %v", err)
+	}
+	if err = response.Body.Close(); err != nil || !body.closed {
+		t.Fatalf("underlying body not closed: %v", err)
+	}
+
+	// 空 body
+	response = &http.Response{Header: http.Header{"Content-Encoding": {"gzip"}}, Body: io.NopCloser(strings.NewReader(""))}
+	DecodeBody(response)
+	if data, err = io.ReadAll(response.Body); err != nil || len(data) != 0 {
+		t.Fatalf("unexpected empty body: %q %v", data, err)
+	}
+}
+
+func TestDecodeBodyJa3(t *testing.T) {
+	var accept atomic.Value
+	server := newEncodingServer(t, &accept)
+	defer server.Close()
+
+	// http2 下由 DecodeBody 解压，包括 tls-client 自身会阻塞的 deflate
+	tlsServer := httptest.NewUnstartedServer(server.Config.Handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session.tlsClient, err = newTlsClient(tls_client.WithInsecureSkipVerify())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, encoding := range []string{"gzip", "deflate", "raw-deflate", "br", "zstd", "gzip,br", "br,gzip", "zstd,deflate,gzip"} {
+		var bodies []string
+		for _, u := range []string{server.URL, server.URL, tlsServer.URL} {
+			builder := ClientBuilder(session).
+				GET(u).
+				Query("e", encoding).
+				Encoding(Encodings...)
+			if len(bodies) > 0 {
+				builder.Ja3()
+			}
+
+			response, err := builder.DoS(http.StatusOK)
+			if err != nil {
+				t.Fatal(err)
+			}
+			bodies = append(bodies, TextResponse(response))
+			_ = response.Body.Close()
+
+			if response.Header.Get("Content-Encoding") != "" {
+				t.Fatalf("%s %s: unexpected header: %v", encoding, u, response.Header)
+			}
+		}
+
+		for i, body := range bodies {
+			if body != encodingText {
+				t.Fatalf("%s: body %d differs: %.64q", encoding, i, body)
+			}
+		}
+	}
+}
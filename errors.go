@@ -20,3 +20,7 @@ func (err Error) Error() (result string) {
 	}
 	return
 }
+
+func (err Error) Unwrap() error {
+	return err.Err
+}
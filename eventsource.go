@@ -0,0 +1,236 @@
+package emit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 连续重连失败次数超过 MaxRetries 时返回
+var ErrStreamRetries = errors.New("event source reconnect attempts exhausted")
+
+// 浏览器 EventSource 语义的长连接：断流后按服务端 retry 间隔重连，并携带 Last-Event-ID。
+// 非200、非 event-stream 的响应视为失败不再重连，204 表示服务端要求关闭
+type EventSource struct {
+	builder *Builder
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu       sync.Mutex
+	response *http.Response
+	reader   *SSEReader
+	closed   bool
+
+	lastId   string
+	retry    time.Duration
+	retries  int
+	failures int
+}
+
+// 建立 EventSource，首次连接失败直接返回错误；Buffer 的内容会被读出缓存，以便重连时重放
+func (c *Builder) Stream() (*EventSource, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	if c.buffer != nil {
+		data, err := io.ReadAll(c.buffer)
+		if err != nil {
+			return nil, Error{-1, "Stream", "", err}
+		}
+		c.bytes, c.buffer = data, nil
+	}
+
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.ctx = ctx
+
+	if !c.hasHeader("Accept") {
+		c.Header("Accept", "text/event-stream")
+	}
+	if !c.hasHeader("Cache-Control") {
+		c.Header("Cache-Control", "no-cache")
+	}
+
+	es := &EventSource{
+		builder: c,
+		ctx:     ctx,
+		cancel:  cancel,
+		retry:   3 * time.Second,
+		retries: -1,
+	}
+
+	if err := es.connect(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return es, nil
+}
+
+// 连续重连失败的最大次数，小于0不限制（默认）
+func (es *EventSource) MaxRetries(retries int) *EventSource {
+	es.retries = retries
+	return es
+}
+
+// 默认重连间隔，服务端下发 retry 后以服务端为准
+func (es *EventSource) RetryInterval(interval time.Duration) *EventSource {
+	es.retry = interval
+	return es
+}
+
+func (es *EventSource) LastEventId() string {
+	return es.lastId
+}
+
+// 当前连接的响应
+func (es *EventSource) Response() *http.Response {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	return es.response
+}
+
+// 读取下一个事件，必要时自动重连；Close 或服务端返回204后返回 io.EOF
+func (es *EventSource) Next() (*SSEEvent, error) {
+	for {
+		es.mu.Lock()
+		reader := es.reader
+		es.mu.Unlock()
+
+		if reader != nil {
+			event, err := reader.Next()
+			if err == nil {
+				es.failures = 0
+				es.lastId = reader.LastEventId()
+				if retry := reader.Retry(); retry > 0 {
+					es.retry = retry
+				}
+				return event, nil
+			}
+
+			es.release()
+			if err = es.interrupted(); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := es.reconnect(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (es *EventSource) Close() error {
+	es.mu.Lock()
+	es.closed = true
+	es.mu.Unlock()
+
+	es.cancel()
+	es.release()
+	return nil
+}
+
+func (es *EventSource) interrupted() error {
+	es.mu.Lock()
+	closed := es.closed
+	es.mu.Unlock()
+
+	if closed {
+		return io.EOF
+	}
+	if err := es.ctx.Err(); err != nil {
+		return Error{-1, "Stream", "", err}
+	}
+	return nil
+}
+
+func (es *EventSource) reconnect() error {
+	for {
+		if es.retries >= 0 && es.failures >= es.retries {
+			return Error{-1, "Stream", "", ErrStreamRetries}
+		}
+		es.failures++
+
+		select {
+		case <-es.ctx.Done():
+			return es.interrupted()
+		case <-time.After(es.retry):
+		}
+
+		err := es.connect()
+		if err == nil {
+			return nil
+		}
+
+		// 只有网络层的异常才重连
+		var e Error
+		if ie := es.interrupted(); ie != nil {
+			return ie
+		}
+		if errors.As(err, &e) && e.Bus != "Do" {
+			return err
+		}
+	}
+}
+
+func (es *EventSource) connect() error {
+	if es.lastId != "" {
+		es.builder.Header("Last-Event-ID", es.lastId)
+	}
+
+	response, err := es.builder.Do()
+	if err != nil {
+		return err
+	}
+
+	if response.StatusCode == http.StatusNoContent {
+		_ = response.Body.Close()
+		es.mu.Lock()
+		es.closed = true
+		es.mu.Unlock()
+		return io.EOF
+	}
+
+	for _, condition := range []func(*http.Response) error{Status(http.StatusOK), IsSTREAM} {
+		if err = condition(response); err != nil {
+			return err
+		}
+	}
+
+	reader := NewSSEReader(response.Body)
+	reader.lastId = es.lastId
+
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.closed {
+		_ = response.Body.Close()
+		return io.EOF
+	}
+	es.response, es.reader = response, reader
+	return nil
+}
+
+func (es *EventSource) release() {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+	if es.response != nil {
+		_ = es.response.Body.Close()
+	}
+	es.response, es.reader = nil, nil
+}
+
+func (c *Builder) hasHeader(key string) bool {
+	for k := range c.headers {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,218 @@
+package emit
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"time"
+)
+
+// 单条消息的默认最大长度
+const EventStreamMaxMessageSize = 16 * 1024 * 1024
+
+// application/vnd.amazon.eventstream 的一条消息。
+// 头部的值按类型解码为 bool、int8、int16、int32、int64、[]byte、string、time.Time、[16]byte（uuid）
+type EventStreamMessage struct {
+	Headers map[string]interface{}
+	Payload []byte
+}
+
+// 解码 AWS event-stream：prelude（总长度、头部长度、CRC32）+ 头部 + 负载 + 整体 CRC32
+type EventStreamReader struct {
+	reader  *bufio.Reader
+	maxSize int
+}
+
+func NewEventStreamReader(reader io.Reader) *EventStreamReader {
+	return &EventStreamReader{
+		reader:  bufio.NewReader(reader),
+		maxSize: EventStreamMaxMessageSize,
+	}
+}
+
+func (r *EventStreamReader) MaxMessageSize(size int) *EventStreamReader {
+	r.maxSize = size
+	return r
+}
+
+// 读取下一条消息，流结束时返回 io.EOF；
+// :message-type 为 exception / error 的消息以 Error 返回，同时返回该消息
+func (r *EventStreamReader) Next() (*EventStreamMessage, error) {
+	var prelude [12]byte
+	if _, err := io.ReadFull(r.reader, prelude[:]); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, Error{-1, "EventStream", "", err}
+	}
+
+	total := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc := crc32.ChecksumIEEE(prelude[:8]); crc != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, Error{-1, "EventStream", "", fmt.Errorf("prelude checksum mismatch: %08x", crc)}
+	}
+
+	if total < 16 || uint64(headersLen) > uint64(total)-16 {
+		return nil, Error{-1, "EventStream", "", fmt.Errorf("invalid message length: %d, headers: %d", total, headersLen)}
+	}
+	if r.maxSize > 0 && uint64(total) > uint64(r.maxSize) {
+		return nil, Error{-1, "EventStream", "", fmt.Errorf("message size %d exceeds limit %d", total, r.maxSize)}
+	}
+
+	message := make([]byte, total)
+	copy(message, prelude[:])
+	if _, err := io.ReadFull(r.reader, message[12:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, Error{-1, "EventStream", "", err}
+	}
+
+	end := total - 4
+	if crc := crc32.ChecksumIEEE(message[:end]); crc != binary.BigEndian.Uint32(message[end:]) {
+		return nil, Error{-1, "EventStream", "", fmt.Errorf("message checksum mismatch: %08x", crc)}
+	}
+
+	headers, err := parseEventStreamHeaders(message[12 : 12+headersLen])
+	if err != nil {
+		return nil, Error{-1, "EventStream", "", err}
+	}
+
+	msg := &EventStreamMessage{
+		Headers: headers,
+		Payload: message[12+headersLen : end],
+	}
+	return msg, msg.err()
+}
+
+func (m *EventStreamMessage) Header(name string) string {
+	if value, ok := m.Headers[name].(string); ok {
+		return value
+	}
+	return ""
+}
+
+func (m *EventStreamMessage) EventType() string {
+	return m.Header(":event-type")
+}
+
+func (m *EventStreamMessage) MessageType() string {
+	return m.Header(":message-type")
+}
+
+func (m *EventStreamMessage) Unmarshal(v interface{}) error {
+	return json.Unmarshal(m.Payload, v)
+}
+
+func (m *EventStreamMessage) err() error {
+	switch m.MessageType() {
+	case "error":
+		return Error{-1, "EventStream", m.Header(":error-message"), errors.New(m.Header(":error-code"))}
+	case "exception":
+		var payload struct {
+			Message string `json:"message"`
+		}
+		msg := string(m.Payload)
+		if json.Unmarshal(m.Payload, &payload) == nil && payload.Message != "" {
+			msg = payload.Message
+		}
+		return Error{-1, "EventStream", msg, errors.New(m.Header(":exception-type"))}
+	default:
+		return nil
+	}
+}
+
+// 逐条回调消息，回调返回false时停止；结束后关闭 body
+func ReadEventStream(response *http.Response, funcCall func(message EventStreamMessage) bool) error {
+	defer response.Body.Close()
+	reader := NewEventStreamReader(response.Body)
+	for {
+		message, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !funcCall(*message) {
+			return nil
+		}
+	}
+}
+
+func parseEventStreamHeaders(data []byte) (map[string]interface{}, error) {
+	headers := make(map[string]interface{})
+	short := errors.New("truncated header")
+
+	for len(data) > 0 {
+		size := int(data[0])
+		if len(data) < 1+size+1 {
+			return nil, short
+		}
+		name := string(data[1 : 1+size])
+		typ := data[1+size]
+		data = data[2+size:]
+
+		var (
+			value interface{}
+			n     int
+		)
+		switch typ {
+		case 0, 1:
+			value = typ == 0
+		case 2:
+			n = 1
+		case 3:
+			n = 2
+		case 4:
+			n = 4
+		case 5, 8:
+			n = 8
+		case 6, 7:
+			if len(data) < 2 {
+				return nil, short
+			}
+			size = int(binary.BigEndian.Uint16(data))
+			data = data[2:]
+			n = size
+		case 9:
+			n = 16
+		default:
+			return nil, fmt.Errorf("unknown header type %d: %s", typ, name)
+		}
+
+		if len(data) < n {
+			return nil, short
+		}
+		raw := data[:n]
+		data = data[n:]
+
+		switch typ {
+		case 2:
+			value = int8(raw[0])
+		case 3:
+			value = int16(binary.BigEndian.Uint16(raw))
+		case 4:
+			value = int32(binary.BigEndian.Uint32(raw))
+		case 5:
+			value = int64(binary.BigEndian.Uint64(raw))
+		case 6:
+			value = append([]byte(nil), raw...)
+		case 7:
+			value = string(raw)
+		case 8:
+			value = time.UnixMilli(int64(binary.BigEndian.Uint64(raw)))
+		case 9:
+			var uuid [16]byte
+			copy(uuid[:], raw)
+			value = uuid
+		}
+		headers[name] = value
+	}
+	return headers, nil
+}
@@ -0,0 +1,130 @@
+package emit
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func encodeEventStream(headers []byte, payload string) []byte {
+	total := 16 + len(headers) + len(payload)
+	message := make([]byte, 12, total)
+	binary.BigEndian.PutUint32(message[0:4], uint32(total))
+	binary.BigEndian.PutUint32(message[4:8], uint32(len(headers)))
+	binary.BigEndian.PutUint32(message[8:12], crc32.ChecksumIEEE(message[:8]))
+	message = append(message, headers...)
+	message = append(message, payload...)
+	return binary.BigEndian.AppendUint32(message, crc32.ChecksumIEEE(message))
+}
+
+func eventStreamHeader(name string, typ byte, value []byte) []byte {
+	header := append([]byte{byte(len(name))}, name...)
+	header = append(header, typ)
+	if typ == 6 || typ == 7 {
+		header = binary.BigEndian.AppendUint16(header, uint16(len(value)))
+	}
+	return append(header, value...)
+}
+
+func TestEventStreamReader(t *testing.T) {
+	// AWS 测试用例 empty_message
+	empty := []byte{0x00, 0x00, 0x00, 0x10, 0x00, 0x00, 0x00, 0x00, 0x05, 0xc2, 0x48, 0xeb, 0x7d, 0x98, 0xc8, 0xff}
+	if !bytes.Equal(encodeEventStream(nil, ""), empty) {
+		t.Fatalf("unexpected empty message: % x", encodeEventStream(nil, ""))
+	}
+
+	var headers []byte
+	headers = append(headers, eventStreamHeader(":message-type", 7, []byte("event"))...)
+	headers = append(headers, eventStreamHeader(":event-type", 7, []byte("chunk"))...)
+	headers = append(headers, eventStreamHeader("true", 0, nil)...)
+	headers = append(headers, eventStreamHeader("byte", 2, []byte{0xff})...)
+	headers = append(headers, eventStreamHeader("short", 3, []byte{0x01, 0x00})...)
+	headers = append(headers, eventStreamHeader("int", 4, binary.BigEndian.AppendUint32(nil, 7))...)
+	headers = append(headers, eventStreamHeader("bytes", 6, []byte{1, 2})...)
+	headers = append(headers, eventStreamHeader("time", 8, binary.BigEndian.AppendUint64(nil, 1700000000000))...)
+
+	var exception []byte
+	exception = append(exception, eventStreamHeader(":message-type", 7, []byte("exception"))...)
+	exception = append(exception, eventStreamHeader(":exception-type", 7, []byte("throttlingException"))...)
+
+	stream := append(empty, encodeEventStream(headers, `{"text":"hi"}`)...)
+	stream = append(stream, encodeEventStream(exception, `{"message":"Too many requests"}`)...)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		_, _ = w.Write(stream)
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).POST(server.URL)
+		if ja3 {
+			builder.Ja3()
+		}
+
+		response, err := builder.DoC(Status(http.StatusOK), IsEVENTSTREAM)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var messages []EventStreamMessage
+		err = ReadEventStream(response, func(message EventStreamMessage) bool {
+			messages = append(messages, message)
+			return true
+		})
+
+		var e Error
+		if !errors.As(err, &e) || e.Msg != "Too many requests" || e.Err.Error() != "throttlingException" {
+			t.Fatalf("ja3=%v expected exception, got %v", ja3, err)
+		}
+		if len(messages) != 2 || len(messages[0].Headers) != 0 {
+			t.Fatalf("ja3=%v unexpected messages: %+v", ja3, messages)
+		}
+
+		message := messages[1]
+		var payload struct {
+			Text string `json:"text"`
+		}
+		if err = message.Unmarshal(&payload); err != nil || payload.Text != "hi" {
+			t.Fatalf("ja3=%v unexpected payload: %s", ja3, message.Payload)
+		}
+		if message.EventType() != "chunk" || message.MessageType() != "event" ||
+			message.Headers["true"] != true ||
+			message.Headers["byte"] != int8(-1) ||
+			message.Headers["short"] != int16(256) ||
+			message.Headers["int"] != int32(7) ||
+			!bytes.Equal(message.Headers["bytes"].([]byte), []byte{1, 2}) ||
+			!message.Headers["time"].(time.Time).Equal(time.UnixMilli(1700000000000)) {
+			t.Fatalf("ja3=%v unexpected headers: %+v", ja3, message.Headers)
+		}
+	}
+}
+
+func TestEventStreamChecksum(t *testing.T) {
+	message := encodeEventStream(eventStreamHeader("a", 7, []byte("b")), "payload")
+	for _, i := range []int{9, len(message) - 5} {
+		corrupted := append([]byte(nil), message...)
+		corrupted[i] ^= 0xff
+
+		_, err := NewEventStreamReader(bytes.NewReader(corrupted)).Next()
+		if err == nil || err == io.EOF {
+			t.Fatalf("expected checksum error at %d, got %v", i, err)
+		}
+	}
+
+	_, err := NewEventStreamReader(bytes.NewReader(message[:len(message)-1])).Next()
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected ErrUnexpectedEOF, got %v", err)
+	}
+}
@@ -0,0 +1,113 @@
+package emit
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type formPart struct {
+	header textproto.MIMEHeader
+	value  []byte
+	path   string
+	reader io.Reader
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// application/x-www-form-urlencoded 请求体
+func (c *Builder) Form(values url.Values) *Builder {
+	c.bytes, c.buffer = []byte(values.Encode()), nil
+	return c.Header("Content-Type", "application/x-www-form-urlencoded")
+}
+
+// multipart 文本字段
+func (c *Builder) Field(name, value string) *Builder {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, quoteEscaper.Replace(name)))
+	c.parts = append(c.parts, formPart{header: header, value: []byte(value)})
+	return c
+}
+
+// multipart 文件字段，每次执行请求时重新打开文件
+func (c *Builder) File(name, path string) *Builder {
+	header := fileHeader(name, filepath.Base(path))
+	c.parts = append(c.parts, formPart{header: header, path: path})
+	return c
+}
+
+// multipart 文件字段，reader 只能被读取一次
+func (c *Builder) FileReader(name, filename string, reader io.Reader) *Builder {
+	c.parts = append(c.parts, formPart{header: fileHeader(name, filename), reader: reader})
+	return c
+}
+
+// 自定义头部的 multipart 字段
+func (c *Builder) Part(header textproto.MIMEHeader, reader io.Reader) *Builder {
+	c.parts = append(c.parts, formPart{header: header, reader: reader})
+	return c
+}
+
+// 以 multipart 流作为请求体：边读边写，不会缓存整个请求体
+func (c *Builder) multipart() (io.ReadCloser, string) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	parts := c.parts
+
+	go func() {
+		var err error
+		for _, part := range parts {
+			if err = writePart(mw, part); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	return pr, mw.FormDataContentType()
+}
+
+func writePart(mw *multipart.Writer, part formPart) error {
+	w, err := mw.CreatePart(part.header)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case part.path != "":
+		file, err := os.Open(part.path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = io.Copy(w, file)
+		return err
+	case part.reader != nil:
+		_, err = io.Copy(w, part.reader)
+		return err
+	default:
+		_, err = w.Write(part.value)
+		return err
+	}
+}
+
+func fileHeader(name, filename string) textproto.MIMEHeader {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		quoteEscaper.Replace(name), quoteEscaper.Replace(filename)))
+
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+	return header
+}
@@ -0,0 +1,133 @@
+package emit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newFormServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := map[string]string{}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			reader, err := r.MultipartReader()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			for {
+				part, err := reader.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				data, _ := io.ReadAll(part)
+				key := part.FormName()
+				if part.FileName() != "" {
+					key += ":" + part.FileName() + ":" + part.Header.Get("Content-Type")
+				}
+				if tag := part.Header.Get("X-Tag"); tag != "" {
+					key += ":" + tag
+				}
+				result[key] = string(data)
+			}
+		} else {
+			if err := r.ParseForm(); err != nil {
+				t.Error(err)
+				return
+			}
+			for k := range r.PostForm {
+				result[k] = strings.Join(r.PostForm[k], ",")
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(result)
+	}))
+}
+
+func TestForm(t *testing.T) {
+	server := newFormServer(t)
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).
+			POST(server.URL).
+			Form(url.Values{"user": {"emit"}, "scope": {"a", "b"}})
+		if ja3 {
+			builder.Ja3()
+		}
+
+		result, err := DoJSON[map[string]string](builder, Status(http.StatusOK))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result["user"] != "emit" || result["scope"] != "a,b" {
+			t.Fatalf("ja3=%v unexpected form: %v", ja3, result)
+		}
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	server := newFormServer(t)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "hello.txt")
+	if err := os.WriteFile(path, []byte("hello file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", `form-data; name="custom"`)
+		header.Set("X-Tag", "raw")
+
+		builder := ClientBuilder(session).
+			POST(server.URL).
+			Header("Content-Type", "application/json").
+			Field("name", `a "quoted" value`).
+			File("file", path).
+			FileReader("blob", "data.bin", strings.NewReader(strings.Repeat("x", 1<<20))).
+			Part(header, strings.NewReader("custom part"))
+		if ja3 {
+			builder.Ja3()
+		}
+
+		result, err := DoJSON[map[string]string](builder, Status(http.StatusOK))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result["name"] != `a "quoted" value` ||
+			result["file:hello.txt:text/plain; charset=utf-8"] != "hello file" ||
+			len(result["blob:data.bin:application/octet-stream"]) != 1<<20 ||
+			result["custom:raw"] != "custom part" {
+			t.Fatalf("ja3=%v unexpected parts: %v", ja3, func() (keys []string) {
+				for k := range result {
+					keys = append(keys, k)
+				}
+				return
+			}())
+		}
+	}
+}
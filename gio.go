@@ -1,40 +1,103 @@
 package emit
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/RomiChan/websocket"
+	"io"
 	"math/rand"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// 主动取消或上下文结束时 Do 返回的错误，可用 errors.Is 判断
+var ErrGioCancelled = errors.New("gio cancelled")
+
+// 数据流在 process_completed 之前断开，或心跳超时
+var ErrGioStreamEnded = errors.New("stream ended before completion")
+
 type JoinEvent struct {
 	Msg     string      `json:"msg"`
 	EventId string      `json:"event_id"`
 	Success bool        `json:"success"`
-	Output  *joinOutput `json:"output"`
+	Message string      `json:"message"`
+	Title   string      `json:"title"`
+	Output  *JoinOutput `json:"output"`
+
+	// estimation
+	Rank      int     `json:"rank"`
+	QueueSize int     `json:"queue_size"`
+	RankEta   float64 `json:"rank_eta"`
+	QueueEta  float64 `json:"queue_eta"`
+
+	// progress
+	ProgressData []JoinProgress `json:"progress_data"`
+
+	// log
+	Log   string `json:"log"`
+	Level string `json:"level"`
 
 	InitialBytes []byte `json:"-"`
 }
 
-type joinOutput struct {
+type JoinOutput struct {
 	Generating      bool    `json:"is_generating"`
 	Duration        float64 `json:"duration"`
 	AverageDuration float64 `json:"average_duration"`
 
+	// process_completed 失败时的信息
+	Error string `json:"error"`
+	Title string `json:"title"`
+
 	Data []interface{} `json:"data"`
 }
 
+type JoinProgress struct {
+	Index    *int     `json:"index"`
+	Length   *int     `json:"length"`
+	Unit     string   `json:"unit"`
+	Progress *float64 `json:"progress"`
+	Desc     string   `json:"desc"`
+}
+
 type GioEmits struct {
 	response *http.Response
 	conn     *websocket.Conn
+	events   <-chan JoinEvent
 	ctx      context.Context
 	em       map[string]func(j JoinEvent) interface{}
 	err      error
-	close    bool
+	close    atomic.Bool
+	protocol string
+
+	// 取消时关闭底层连接，避免阻塞在 Scan / ReadMessage
+	done     chan struct{}
+	doneOnce sync.Once
+	mu       sync.Mutex
+
+	// 最近一次收到消息的时间，超过 heartbeat 未收到任何消息视为断流
+	beat      atomic.Int64
+	heartbeat time.Duration
+	stale     atomic.Bool
+
+	// 复用的数据流不因单个任务结束而终止
+	multiplex bool
+
+	onCancel   func()
+	cancelOnce sync.Once
+
+	out    chan JoinEvent
+	result error
+	wmu    sync.Mutex
+
+	// sse_v2+ 每个event_id当前的完整输出
+	streams   map[string][]interface{}
+	completed *JoinEvent
 }
 
 func NewGio(ctx context.Context, coupler interface{}) (e *GioEmits, err error) {
@@ -47,6 +110,8 @@ func NewGio(ctx context.Context, coupler interface{}) (e *GioEmits, err error) {
 		em:  map[string]func(j JoinEvent) interface{}{
 			//
 		},
+		streams: make(map[string][]interface{}),
+		done:    make(chan struct{}),
 	}
 
 	switch c := coupler.(type) {
@@ -54,8 +119,12 @@ func NewGio(ctx context.Context, coupler interface{}) (e *GioEmits, err error) {
 		e.response = c
 	case *websocket.Conn:
 		e.conn = c
+	case <-chan JoinEvent:
+		e.events = c
+	case chan JoinEvent:
+		e.events = c
 	default:
-		return nil, errors.New("'coupler' must be *http.Response or *websocket.Conn")
+		return nil, errors.New("'coupler' must be *http.Response, *websocket.Conn or <-chan JoinEvent")
 	}
 	return
 }
@@ -65,24 +134,131 @@ func (e *GioEmits) Event(eventId string, funcCall func(j JoinEvent) interface{})
 	e.em[eventId] = funcCall
 }
 
+// 设置协议版本：ws、sse、sse_v1、sse_v2、sse_v2.1、sse_v3
+func (e *GioEmits) Protocol(protocol string) {
+	e.protocol = protocol
+}
+
+// 心跳超时，超过该时长未收到任何消息（含 heartbeat）则断开并返回 ErrGioStreamEnded
+func (e *GioEmits) HeartbeatTimeout(timeout time.Duration) {
+	e.heartbeat = timeout
+}
+
+// 最近一次收到消息的时间
+func (e *GioEmits) Heartbeat() time.Time {
+	return time.Unix(0, e.beat.Load())
+}
+
 // 异常设置，并终止事件
 func (e *GioEmits) Failed(err error) {
+	e.mu.Lock()
 	e.err = err
+	e.mu.Unlock()
 	e.Cancel()
 }
 
-// 终止事件
+// 终止事件，并执行 OnCancel 注册的远端取消
 func (e *GioEmits) Cancel() {
-	e.close = true
+	e.close.Store(true)
+	e.interrupt()
+	e.remoteCancel()
+}
+
+// 注册取消回调，Cancel 或上下文结束时执行一次
+func (e *GioEmits) OnCancel(funcCall func()) {
+	e.onCancel = funcCall
+}
+
+func (e *GioEmits) remoteCancel() {
+	e.cancelOnce.Do(func() {
+		if e.onCancel != nil {
+			e.onCancel()
+		}
+	})
+}
+
+func (e *GioEmits) interrupt() {
+	e.doneOnce.Do(func() {
+		close(e.done)
+		e.closeCoupler()
+	})
+}
+
+func (e *GioEmits) closeCoupler() {
+	if e.response != nil {
+		_ = e.response.Body.Close()
+	}
+	if e.conn != nil {
+		_ = e.conn.Close()
+	}
+}
+
+func (e *GioEmits) watch() (stop func()) {
+	e.beat.Store(time.Now().UnixNano())
+	if e.heartbeat <= 0 || e.events != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(e.heartbeat / 4)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if time.Since(e.Heartbeat()) > e.heartbeat {
+					e.stale.Store(true)
+					e.closeCoupler()
+					return
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// 上下文结束或已取消时返回取消错误，心跳超时返回 ErrGioStreamEnded
+func (e *GioEmits) interrupted() error {
+	if err := e.ctx.Err(); err != nil {
+		return e.cancelled(err)
+	}
+	if e.close.Load() {
+		return e.cancelled(nil)
+	}
+	if e.stale.Load() {
+		return Error{-1, "Gio", "heartbeat timeout", ErrGioStreamEnded}
+	}
+	return nil
+}
+
+func (e *GioEmits) cancelled(cause error) error {
+	e.remoteCancel()
+	if cause == nil {
+		return Error{-1, "Gio", "", ErrGioCancelled}
+	}
+	return Error{-1, "Gio", "", fmt.Errorf("%w: %w", ErrGioCancelled, cause)}
+}
+
+// 最后收到的 process_completed 事件，未结束时为nil
+func (e *GioEmits) Completed() *JoinEvent {
+	return e.completed
 }
 
 func (e *GioEmits) Do() error {
-	if e.conn == nil && e.response == nil {
+	if e.conn == nil && e.response == nil && e.events == nil {
 		panic("'coupler' is nil, please provide a valid 'coupler' value")
 	}
 
+	stop := context.AfterFunc(e.ctx, e.interrupt)
+	defer stop()
+	defer e.watch()()
+
 	if e.conn != nil {
 		return e.warpE(e.doConn())
+	} else if e.events != nil {
+		return e.warpE(e.doChan())
 	} else {
 		return e.warpE(e.doResponse())
 	}
@@ -90,7 +266,7 @@ func (e *GioEmits) Do() error {
 
 // 异步执行
 func (e *GioEmits) DoAsync() chan error {
-	if e.conn == nil && e.response == nil {
+	if e.conn == nil && e.response == nil && e.events == nil {
 		panic("'coupler' is nil, please provide a valid 'coupler' value")
 	}
 
@@ -101,7 +277,53 @@ func (e *GioEmits) DoAsync() chan error {
 	return err
 }
 
+// 以通道方式消费事件，并在后台执行 Do。
+//
+// 通道无缓冲：消费方未取走事件前不会继续读取数据流（背压），上下文结束时丢弃未取走的事件；
+// Do 结束后通道关闭，结束原因通过 Err 获取。已注册的处理器仍会先于通道执行
+func (e *GioEmits) Events() <-chan JoinEvent {
+	if e.conn == nil && e.response == nil && e.events == nil {
+		panic("'coupler' is nil, please provide a valid 'coupler' value")
+	}
+
+	out := make(chan JoinEvent)
+	e.out = out
+	go func() {
+		defer close(out)
+		err := e.Do()
+		e.mu.Lock()
+		e.result = err
+		e.mu.Unlock()
+	}()
+	return out
+}
+
+// Events 通道关闭后的结束原因
+func (e *GioEmits) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.result
+}
+
+// 向websocket回写消息，与处理器返回值等效，可在通道消费时使用
+func (e *GioEmits) Send(v interface{}) error {
+	if e.conn == nil {
+		return errors.New("'Send' requires a *websocket.Conn coupler")
+	}
+
+	marshal, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	e.wmu.Lock()
+	defer e.wmu.Unlock()
+	return e.conn.WriteMessage(websocket.TextMessage, marshal)
+}
+
 func (e *GioEmits) warpE(err error) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
 	if e.err != nil {
 		return e.err
 	}
@@ -110,123 +332,157 @@ func (e *GioEmits) warpE(err error) error {
 
 func (e *GioEmits) doConn() error {
 	for {
-		select {
-		case <-e.ctx.Done():
-			return e.ctx.Err()
-		default:
-			if e.close {
-				return nil
-			}
-
-			_, data, err := e.conn.ReadMessage()
-			if err != nil {
-				return err
-			}
+		if err := e.interrupted(); err != nil {
+			return err
+		}
 
-			var j JoinEvent
-			err = json.Unmarshal(data, &j)
-			if err != nil {
-				return err
+		_, data, err := e.conn.ReadMessage()
+		if err != nil {
+			if ie := e.interrupted(); ie != nil {
+				return ie
 			}
+			return err
+		}
+		e.beat.Store(time.Now().UnixNano())
 
-			var marshal []byte
-			j.InitialBytes = data
-
-			if funcCall, ok := e.em[j.Msg]; ok {
-				if r := funcCall(j); r != nil {
-					marshal, err = json.Marshal(r)
-					if err != nil {
-						return err
-					}
+		var j JoinEvent
+		err = json.Unmarshal(data, &j)
+		if err != nil {
+			return err
+		}
 
-					err = e.conn.WriteMessage(websocket.TextMessage, marshal)
-					if err != nil {
-						return err
-					}
-				}
-			}
+		j.InitialBytes = data
+		if err = e.patch(&j); err != nil {
+			return err
+		}
 
-			if funcCall, ok := e.em["*"]; ok {
-				if r := funcCall(j); r != nil {
-					marshal, err = json.Marshal(r)
-					if err != nil {
-						return err
-					}
-
-					err = e.conn.WriteMessage(websocket.TextMessage, marshal)
-					if err != nil {
-						return err
-					}
-				}
+		for _, r := range e.dispatch(j) {
+			if err = e.Send(r); err != nil {
+				return err
 			}
+		}
 
-			if j.Success && j.Msg == "process_completed" {
+		switch j.Msg {
+		case "process_completed":
+			e.completed = &j
+			if j.Success {
 				return nil
 			}
+		case "queue_full":
+			return Error{-1, "Gio", "", ErrGradioQueueFull}
 		}
 	}
 }
 
 func (e *GioEmits) doResponse() error {
-	scanner := bufio.NewScanner(e.response.Body)
-	scanner.Split(func(data []byte, eof bool) (advance int, token []byte, err error) {
-		if eof && len(data) == 0 {
-			return 0, nil, nil
+	reader := NewSSEReader(e.response.Body)
+	for {
+		if err := e.interrupted(); err != nil {
+			return err
 		}
 
-		if i := bytes.IndexByte(data, '\n'); i >= 0 {
-			return i + 1, data[0:i], nil
+		event, err := reader.Next()
+		if err != nil {
+			if ie := e.interrupted(); ie != nil {
+				return ie
+			}
+			if err != io.EOF {
+				return err
+			}
+			// 复用的数据流由 GioMux 判断是否还有未完成的任务
+			if e.multiplex || e.completed == nil {
+				return Error{-1, "Gio", "", ErrGioStreamEnded}
+			}
+			return nil
 		}
+		e.beat.Store(time.Now().UnixNano())
 
-		if eof {
-			return len(data), data, nil
+		data := event.Data
+		if data == "" {
+			continue
 		}
 
-		return 0, nil, nil
-	})
+		var j JoinEvent
+		j.InitialBytes = []byte(data)
 
-	for {
-		select {
-		case <-e.ctx.Done():
-			return e.ctx.Err()
-		default:
-			if e.close {
-				return nil
-			}
+		err = json.Unmarshal(j.InitialBytes, &j)
+		if err != nil {
+			return err
+		}
 
-			if !scanner.Scan() {
-				return nil
-			}
+		if err = e.patch(&j); err != nil {
+			return err
+		}
 
-			data := scanner.Text()
-			if len(data) < 6 || data[:6] != "data: " {
+		e.dispatch(j)
+		switch j.Msg {
+		case "process_completed":
+			if e.multiplex {
 				continue
 			}
-			data = data[6:]
-
-			var j JoinEvent
-			j.InitialBytes = []byte(data)
-
-			err := json.Unmarshal(j.InitialBytes, &j)
-			if err != nil {
-				return err
+			e.completed = &j
+			if j.Success {
+				return nil
 			}
+		case "close_stream":
+			return nil
+		case "queue_full":
+			return Error{-1, "Gio", "", ErrGradioQueueFull}
+		case "unexpected_error":
+			return Error{-1, "Gio", j.Message, errors.New("unexpected error")}
+		}
+	}
+}
 
-			if funcCall, ok := e.em[j.Msg]; ok {
-				funcCall(j)
+// 事件已由 GioMux 按 event_id 分发并合并过diff
+func (e *GioEmits) doChan() error {
+	for {
+		select {
+		case <-e.ctx.Done():
+			return e.cancelled(e.ctx.Err())
+		case <-e.done:
+			return e.interrupted()
+		case j, ok := <-e.events:
+			if err := e.interrupted(); err != nil {
+				return err
 			}
-
-			if funcCall, ok := e.em["*"]; ok {
-				funcCall(j)
+			if !ok {
+				return nil
 			}
 
-			if j.Success && j.Msg == "process_completed" {
+			e.dispatch(j)
+			if j.Msg == "process_completed" {
+				e.completed = &j
 				return nil
 			}
 		}
 	}
 }
 
+// 依次执行 msg、"*" 处理器并写入 Events 通道，返回处理器需要回写的消息
+func (e *GioEmits) dispatch(j JoinEvent) (replies []interface{}) {
+	if funcCall, ok := e.em[j.Msg]; ok {
+		if r := funcCall(j); r != nil {
+			replies = append(replies, r)
+		}
+	}
+
+	if funcCall, ok := e.em["*"]; ok {
+		if r := funcCall(j); r != nil {
+			replies = append(replies, r)
+		}
+	}
+
+	if e.out != nil {
+		select {
+		case e.out <- j:
+		case <-e.ctx.Done():
+		case <-e.done:
+		}
+	}
+	return
+}
+
 func GioHash() string {
 	bin := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ1234567890"
 	binL := len(bin)
@@ -0,0 +1,201 @@
+package emit
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// sse_v2 起 process_generating 只下发首帧完整输出，之后每个输出都是 [action, path, value] 列表
+func (e *GioEmits) diffable() bool {
+	switch e.protocol {
+	case "sse_v2", "sse_v2.1", "sse_v3":
+		return true
+	default:
+		return false
+	}
+}
+
+// 将diff合并为完整输出，处理器拿到的 Output.Data 始终是当前完整值
+func (e *GioEmits) patch(j *JoinEvent) (err error) {
+	if !e.diffable() {
+		return
+	}
+
+	switch j.Msg {
+	case "process_generating":
+		if j.Output == nil {
+			return
+		}
+
+		current, ok := e.streams[j.EventId]
+		if !ok {
+			e.streams[j.EventId] = cloneValue(j.Output.Data).([]interface{})
+			return
+		}
+
+		for i, diff := range j.Output.Data {
+			if i >= len(current) {
+				current = append(current, nil)
+			}
+			current[i], err = applyDiff(current[i], diff)
+			if err != nil {
+				return Error{-1, "Gio", string(j.InitialBytes), err}
+			}
+		}
+
+		// 深拷贝，避免后续diff修改已分发出去的输出
+		e.streams[j.EventId] = current
+		j.Output.Data = cloneValue(current).([]interface{})
+	case "process_completed":
+		delete(e.streams, j.EventId)
+	}
+	return
+}
+
+func applyDiff(target interface{}, diff interface{}) (interface{}, error) {
+	edits, ok := diff.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid diff: %v", diff)
+	}
+
+	var err error
+	for _, edit := range edits {
+		op, ok := edit.([]interface{})
+		if !ok || len(op) != 3 {
+			return nil, fmt.Errorf("invalid diff edit: %v", edit)
+		}
+
+		action, _ := op[0].(string)
+		path, _ := op[1].([]interface{})
+		target, err = applyEdit(target, path, action, op[2])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return target, nil
+}
+
+func applyEdit(target interface{}, path []interface{}, action string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		switch action {
+		case "replace":
+			return value, nil
+		case "append":
+			return appendValue(target, value)
+		default:
+			return nil, fmt.Errorf("unsupported action: %s", action)
+		}
+	}
+
+	var err error
+	switch current := target.(type) {
+	case map[string]interface{}:
+		key := fmt.Sprint(path[0])
+		if len(path) > 1 {
+			current[key], err = applyEdit(current[key], path[1:], action, value)
+			return current, err
+		}
+
+		switch action {
+		case "replace", "add":
+			current[key] = value
+		case "append":
+			current[key], err = appendValue(current[key], value)
+		case "delete":
+			delete(current, key)
+		default:
+			err = fmt.Errorf("unsupported action: %s", action)
+		}
+		return current, err
+
+	case []interface{}:
+		index, err := diffIndex(path[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if action == "add" && len(path) == 1 {
+			if index > len(current) {
+				return nil, fmt.Errorf("diff index out of range: %d", index)
+			}
+			current = append(current, nil)
+			copy(current[index+1:], current[index:])
+			current[index] = value
+			return current, nil
+		}
+
+		if index >= len(current) {
+			return nil, fmt.Errorf("diff index out of range: %d", index)
+		}
+
+		if len(path) > 1 {
+			current[index], err = applyEdit(current[index], path[1:], action, value)
+			return current, err
+		}
+
+		switch action {
+		case "replace":
+			current[index] = value
+		case "append":
+			current[index], err = appendValue(current[index], value)
+		case "delete":
+			current = append(current[:index], current[index+1:]...)
+		default:
+			err = fmt.Errorf("unsupported action: %s", action)
+		}
+		return current, err
+
+	default:
+		return nil, fmt.Errorf("cannot apply diff path %v on %T", path, target)
+	}
+}
+
+func appendValue(target, value interface{}) (interface{}, error) {
+	switch t := target.(type) {
+	case nil:
+		return value, nil
+	case string:
+		if v, ok := value.(string); ok {
+			return t + v, nil
+		}
+	case []interface{}:
+		if v, ok := value.([]interface{}); ok {
+			return append(t, v...), nil
+		}
+		return append(t, value), nil
+	}
+	return nil, fmt.Errorf("cannot append %T to %T", value, target)
+}
+
+func diffIndex(key interface{}) (int, error) {
+	switch k := key.(type) {
+	case float64:
+		if k >= 0 {
+			return int(k), nil
+		}
+	case string:
+		if index, err := strconv.Atoi(k); err == nil && index >= 0 {
+			return index, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid diff index: %v", key)
+}
+
+func cloneValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = cloneValue(item)
+		}
+		return m
+	case []interface{}:
+		slice := make([]interface{}, len(v))
+		for i, item := range v {
+			slice[i] = cloneValue(item)
+		}
+		return slice
+	default:
+		return value
+	}
+}
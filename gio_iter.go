@@ -0,0 +1,25 @@
+//go:build go1.23
+
+package emit
+
+import "iter"
+
+// 以迭代器方式消费事件，语义与 Events 相同；提前 break 会取消任务，
+// 异常结束时最后一次迭代返回 (JoinEvent{}, err)
+func (e *GioEmits) All() iter.Seq2[JoinEvent, error] {
+	return func(yield func(JoinEvent, error) bool) {
+		events := e.Events()
+		for j := range events {
+			if !yield(j, nil) {
+				e.Cancel()
+				for range events {
+				}
+				return
+			}
+		}
+
+		if err := e.Err(); err != nil {
+			yield(JoinEvent{}, err)
+		}
+	}
+}
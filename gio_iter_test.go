@@ -0,0 +1,48 @@
+//go:build go1.23
+
+package emit
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func TestGioIterator(t *testing.T) {
+	server := newGradioServer(t, "sse_v3", func(eventId string, _ []interface{}) []string {
+		return []string{
+			fmt.Sprintf(`{"msg":"process_starts","event_id":"%s"}`, eventId),
+			fmt.Sprintf(`{"msg":"process_generating","event_id":"%s","success":true,"output":{"data":["a"]}}`, eventId),
+			fmt.Sprintf(`{"msg":"process_generating","event_id":"%s","success":true,"output":{"data":[[["append",[],"b"]]]}}`, eventId),
+			fmt.Sprintf(`{"msg":"process_completed","event_id":"%s","success":true,"output":{"data":["ab"]}}`, eventId),
+		}
+	})
+	defer server.Close()
+
+	client, err := NewGradio(context.Background(), nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	job, err := client.Submit(context.Background(), "/chat", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages []string
+	for j, err := range job.All() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, j.Msg)
+		if j.Msg == "process_generating" {
+			messages = append(messages, j.Output.Data[0].(string))
+		}
+	}
+
+	expected := "[process_starts process_generating a process_generating ab process_completed]"
+	if fmt.Sprint(messages) != expected {
+		t.Fatalf("unexpected events: %v", messages)
+	}
+}
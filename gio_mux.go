@@ -0,0 +1,322 @@
+package emit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// 未订阅的事件、已结束的 event_id 的保留时间
+const gioPendingTTL = time.Minute
+
+// 同一 session_hash 下共用一条 /queue/data 流，按 event_id 分发给各个任务
+//
+// ws、sse 协议每个任务本身就是独立连接，不需要复用
+type GioMux struct {
+	mu       sync.Mutex
+	protocol string
+	open     func(ctx context.Context) (*http.Response, error)
+	subs     map[string]*gioSub
+	pending  map[string]*gioPending
+	finished map[string]time.Time
+	swept    time.Time
+	cancel   context.CancelFunc
+	err      error
+
+	// 断流重连次数与心跳超时
+	attempts  int
+	heartbeat time.Duration
+	// 跨重连保留的diff状态
+	streams map[string][]interface{}
+}
+
+type gioPending struct {
+	events []JoinEvent
+	at     time.Time
+}
+
+type gioSub struct {
+	in   chan JoinEvent
+	done chan struct{}
+	once sync.Once
+}
+
+func NewGioMux(protocol string, open func(ctx context.Context) (*http.Response, error)) *GioMux {
+	return &GioMux{
+		protocol: protocol,
+		open:     open,
+		subs:     make(map[string]*gioSub),
+		pending:  make(map[string]*gioPending),
+		finished: make(map[string]time.Time),
+		attempts: 3,
+		streams:  make(map[string][]interface{}),
+	}
+}
+
+// 仍有未完成的任务时，数据流断开最多重连 attempts 次；heartbeat > 0 时超时未收到消息也视为断流
+func (m *GioMux) Reconnect(attempts int, heartbeat time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attempts = attempts
+	m.heartbeat = heartbeat
+}
+
+// 订阅 event_id，收到 process_completed 或流结束后通道关闭；cancel 用于提前退订
+func (m *GioMux) Subscribe(eventId string) (<-chan JoinEvent, func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub := &gioSub{
+		in:   make(chan JoinEvent),
+		done: make(chan struct{}),
+	}
+	out := sub.pump()
+	cancel := func() {
+		m.mu.Lock()
+		if m.subs[eventId] == sub {
+			delete(m.subs, eventId)
+			close(sub.in)
+		}
+		m.finish(eventId)
+		m.mu.Unlock()
+		sub.once.Do(func() { close(sub.done) })
+	}
+
+	// 订阅前已经到达的事件
+	if p, ok := m.pending[eventId]; ok {
+		delete(m.pending, eventId)
+		for _, j := range p.events {
+			sub.in <- j
+			if j.Msg == "process_completed" {
+				m.finish(eventId)
+				close(sub.in)
+				return out, cancel
+			}
+		}
+	}
+
+	m.subs[eventId] = sub
+	if m.cancel == nil {
+		m.start()
+	}
+	return out, cancel
+}
+
+// 最近一次数据流的异常
+func (m *GioMux) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+func (m *GioMux) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+	}
+}
+
+func (m *GioMux) start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	m.err = nil
+
+	go func() {
+		idle, err := m.run(ctx)
+		cancel()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.cancel = nil
+
+		// run 判断没有订阅之后、清理之前又有新的订阅，重新建立数据流
+		if idle && len(m.subs) > 0 {
+			m.start()
+			return
+		}
+
+		m.err = err
+		for eventId, sub := range m.subs {
+			delete(m.subs, eventId)
+			close(sub.in)
+		}
+	}()
+}
+
+// idle 为 true 表示因没有未完成的订阅而结束
+func (m *GioMux) run(ctx context.Context) (idle bool, err error) {
+	m.mu.Lock()
+	attempts, heartbeat := m.attempts, m.heartbeat
+	m.mu.Unlock()
+
+	for retry := 0; ; retry++ {
+		err = m.stream(ctx, heartbeat)
+		if !m.waiting() {
+			if errors.Is(err, ErrGioStreamEnded) {
+				return true, nil
+			}
+			return true, err
+		}
+
+		if err == nil {
+			err = Error{-1, "Gio", "", ErrGioStreamEnded}
+		}
+
+		if ctx.Err() != nil || !gioReconnectable(err) || retry >= attempts {
+			return false, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, err
+		case <-time.After(time.Duration(retry+1) * 500 * time.Millisecond):
+		}
+	}
+}
+
+func (m *GioMux) stream(ctx context.Context, heartbeat time.Duration) error {
+	response, err := m.open(ctx)
+	if err != nil {
+		return err
+	}
+
+	e, err := NewGio(ctx, response)
+	if err != nil {
+		return err
+	}
+
+	e.Protocol(m.protocol)
+	e.HeartbeatTimeout(heartbeat)
+	e.multiplex = true
+	e.streams = m.streams
+	e.Event("*", func(j JoinEvent) interface{} {
+		m.dispatch(j)
+		return nil
+	})
+	return e.Do()
+}
+
+// 是否还有未完成的订阅
+func (m *GioMux) waiting() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.subs) > 0
+}
+
+// 断流、网络异常可以重连；取消、服务端明确返回的错误不重连
+func gioReconnectable(err error) bool {
+	if errors.Is(err, ErrGioStreamEnded) {
+		return true
+	}
+	if errors.Is(err, ErrGioCancelled) {
+		return false
+	}
+
+	var e Error
+	if errors.As(err, &e) {
+		return e.Bus != "Gio" && (e.Code == -1 || e.Code >= http.StatusInternalServerError)
+	}
+	return true
+}
+
+func (m *GioMux) dispatch(j JoinEvent) {
+	if j.EventId == "" {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// 已取消、已完成的任务的后续事件直接丢弃
+	m.sweep()
+	if _, ok := m.finished[j.EventId]; ok {
+		delete(m.streams, j.EventId)
+		return
+	}
+
+	sub, ok := m.subs[j.EventId]
+	if !ok {
+		p, ok := m.pending[j.EventId]
+		if !ok {
+			p = &gioPending{at: time.Now()}
+			m.pending[j.EventId] = p
+		}
+		p.events = append(p.events, j)
+		return
+	}
+
+	sub.in <- j
+	if j.Msg == "process_completed" {
+		delete(m.subs, j.EventId)
+		close(sub.in)
+		m.finish(j.EventId)
+	}
+}
+
+// 记录已结束的 event_id，需持有锁
+func (m *GioMux) finish(eventId string) {
+	m.finished[eventId] = time.Now()
+	delete(m.pending, eventId)
+}
+
+// 清理超过 gioPendingTTL 的未订阅事件与已结束的 event_id，需持有锁
+func (m *GioMux) sweep() {
+	now := time.Now()
+	if now.Sub(m.swept) < gioPendingTTL/2 {
+		return
+	}
+	m.swept = now
+
+	for eventId, p := range m.pending {
+		if now.Sub(p.at) > gioPendingTTL {
+			delete(m.pending, eventId)
+		}
+	}
+	for eventId, at := range m.finished {
+		if now.Sub(at) > gioPendingTTL {
+			delete(m.finished, eventId)
+		}
+	}
+}
+
+// 无界缓冲，避免某个任务消费慢阻塞整条数据流
+func (sub *gioSub) pump() <-chan JoinEvent {
+	out := make(chan JoinEvent)
+	go func() {
+		defer close(out)
+
+		var queue []JoinEvent
+		in := sub.in
+		for in != nil || len(queue) > 0 {
+			if len(queue) == 0 {
+				select {
+				case j, ok := <-in:
+					if !ok {
+						return
+					}
+					queue = append(queue, j)
+				case <-sub.done:
+					return
+				}
+				continue
+			}
+
+			select {
+			case j, ok := <-in:
+				if !ok {
+					in = nil
+					continue
+				}
+				queue = append(queue, j)
+			case out <- queue[0]:
+				queue = queue[1:]
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+	return out
+}
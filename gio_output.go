@@ -0,0 +1,80 @@
+package emit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// 按位置把 Data 映射到结构体字段：
+//
+//	type Result struct {
+//		History [][]string          // data[0]
+//		Image   GradioFile `gio:"2"` // data[2]
+//		Ignored string     `gio:"-"`
+//	}
+//
+// 未指定 gio 标签的字段依次对应上一个字段的下一个位置
+func (output *JoinOutput) Decode(v interface{}) error {
+	if output == nil {
+		return errors.New("output is nil")
+	}
+	return decodeData(output.Data, v)
+}
+
+func DecodeJoin[T any](output *JoinOutput) (value T, err error) {
+	err = output.Decode(&value)
+	return
+}
+
+func decodeData(data []interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("decode target must be a non-nil pointer")
+	}
+
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		bin, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(bin, v)
+	}
+
+	rt := rv.Type()
+	index := 0
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if tag, ok := field.Tag.Lookup("gio"); ok {
+			if tag == "-" {
+				continue
+			}
+			pos, err := strconv.Atoi(tag)
+			if err != nil || pos < 0 {
+				return fmt.Errorf("invalid gio tag on field %s: %q", field.Name, tag)
+			}
+			index = pos
+		}
+
+		if index >= len(data) {
+			continue
+		}
+
+		bin, err := json.Marshal(data[index])
+		if err != nil {
+			return err
+		}
+		if err = json.Unmarshal(bin, rv.Field(i).Addr().Interface()); err != nil {
+			return fmt.Errorf("decode data[%d] into field %s: %w", index, field.Name, err)
+		}
+		index++
+	}
+	return nil
+}
@@ -1,12 +1,13 @@
 package emit
 
 import (
-	"bufio"
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"github.com/RomiChan/websocket"
 	"github.com/bogdanfinn/tls-client/profiles"
 	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
@@ -332,38 +333,102 @@ func TestAPI(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	scanner := bufio.NewScanner(response.Body)
-	scanner.Split(func(data []byte, eof bool) (advance int, token []byte, err error) {
-		if eof && len(data) == 0 {
-			return 0, nil, nil
+	err = ReadSSE(response, func(event SSEEvent) bool {
+		if event.Data == "[DONE]" {
+			return false
 		}
+		t.Log(event.Data)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
 
-		if i := bytes.IndexByte(data, '\n'); i >= 0 {
-			return i + 1, data[0:i], nil
+func TestGioCancelResponsive(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ws" {
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"msg":"send_hash"}`))
+			_, _, _ = conn.ReadMessage()
+			return
 		}
 
-		if eof {
-			return len(data), data, nil
-		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"msg\":\"estimation\",\"event_id\":\"x\"}\n\n"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
 
-		return 0, nil, nil
-	})
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	for {
-		if !scanner.Scan() {
-			break
+	stream := func() interface{} {
+		response, err := ClientBuilder(session).GET(server.URL).DoS(http.StatusOK)
+		if err != nil {
+			t.Fatal(err)
 		}
+		return response
+	}
 
-		data := scanner.Text()
-		if len(data) < 6 || data[:6] != "data: " {
-			continue
+	socket := func() interface{} {
+		conn, _, err := SocketBuilder(session).
+			URL("ws" + strings.TrimPrefix(server.URL, "http") + "/ws").
+			DoS(http.StatusSwitchingProtocols)
+		if err != nil {
+			t.Fatal(err)
 		}
+		return conn
+	}
 
-		data = data[6:]
-		if data == "[DONE]" {
-			break
-		}
+	for name, coupler := range map[string]func() interface{}{"response": stream, "conn": socket} {
+		t.Run(name+"/context", func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			e, err := NewGio(ctx, coupler())
+			if err != nil {
+				t.Fatal(err)
+			}
 
-		t.Log(data)
+			select {
+			case err = <-e.DoAsync():
+				if !errors.Is(err, ErrGioCancelled) || !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("Do blocked after context deadline")
+			}
+		})
+
+		t.Run(name+"/cancel", func(t *testing.T) {
+			e, err := NewGio(context.Background(), coupler())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cancelled := make(chan struct{})
+			e.OnCancel(func() { close(cancelled) })
+			ch := e.DoAsync()
+			time.AfterFunc(100*time.Millisecond, e.Cancel)
+
+			select {
+			case err = <-ch:
+				if !errors.Is(err, ErrGioCancelled) {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("Do blocked after Cancel")
+			}
+			<-cancelled
+		})
 	}
 }
@@ -5,19 +5,19 @@ require (
 	github.com/andybalholm/brotli v1.1.0
 	github.com/bogdanfinn/fhttp v0.5.28
 	github.com/bogdanfinn/tls-client v1.7.7
+	github.com/klauspost/compress v1.17.8
 	golang.org/x/net v0.25.0
+	golang.org/x/text v0.15.0
 )
 
 require (
 	github.com/bogdanfinn/utls v1.6.1 // indirect
 	github.com/cloudflare/circl v1.3.8 // indirect
-	github.com/klauspost/compress v1.17.8 // indirect
 	github.com/quic-go/quic-go v0.42.0 // indirect
 	github.com/tam7t/hpkp v0.0.0-20160821193359-2b70b4024ed5 // indirect
 	golang.org/x/crypto v0.23.0 // indirect
 	golang.org/x/exp v0.0.0-20221205204356-47842c84f3db // indirect
 	golang.org/x/sys v0.20.0 // indirect
-	golang.org/x/text v0.15.0 // indirect
 )
 
 go 1.21.6
@@ -0,0 +1,736 @@
+package emit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type GradioConfig struct {
+	Version      string             `json:"version"`
+	Mode         string             `json:"mode"`
+	Protocol     string             `json:"protocol"`
+	Root         string             `json:"root"`
+	ApiPrefix    string             `json:"api_prefix"`
+	EnableQueue  bool               `json:"enable_queue"`
+	Dependencies []GradioDependency `json:"dependencies"`
+}
+
+type GradioDependency struct {
+	Id      *int          `json:"id"`
+	ApiName interface{}   `json:"api_name"`
+	Targets []interface{} `json:"targets"`
+	Queue   *bool         `json:"queue"`
+}
+
+type GradioInfo struct {
+	NamedEndpoints   map[string]GradioEndpointInfo `json:"named_endpoints"`
+	UnnamedEndpoints map[string]GradioEndpointInfo `json:"unnamed_endpoints"`
+}
+
+type GradioEndpointInfo struct {
+	Parameters []GradioParameter `json:"parameters"`
+	Returns    []GradioParameter `json:"returns"`
+}
+
+type GradioParameter struct {
+	Label         string      `json:"label"`
+	ParameterName string      `json:"parameter_name"`
+	Component     string      `json:"component"`
+	Type          interface{} `json:"type"`
+	HasDefault    bool        `json:"parameter_has_default"`
+	Default       interface{} `json:"parameter_default"`
+}
+
+type GradioEndpoint struct {
+	Name      string
+	FnIndex   int
+	TriggerId *int
+	Info      *GradioEndpointInfo
+	// 为 false 时不经过队列，直接 POST /run/predict
+	Queue bool
+}
+
+type GradioClient struct {
+	root    string
+	hub     string
+	hash    string
+	proxies string
+	ja3     bool
+	headers map[string]string
+	cookies string
+	mu      sync.Mutex
+
+	session *Session
+	config  *GradioConfig
+	info    *GradioInfo
+	mux     *GioMux
+
+	attempts  int
+	heartbeat time.Duration
+}
+
+type GradioJob struct {
+	*GioEmits
+	// sse 协议在收到 send_data 后才会赋值
+	EventId  string
+	Endpoint *GradioEndpoint
+
+	client  *GradioClient
+	release func()
+	mu      sync.Mutex
+}
+
+type GradioHelper = func(client *GradioClient) error
+
+func GradioProxiesHelper(proxies string) GradioHelper {
+	return func(client *GradioClient) error {
+		client.proxies = proxies
+		return nil
+	}
+}
+
+// http请求走 tls-client，指纹由 Session 的 Ja3Helper 决定
+func GradioJa3Helper() GradioHelper {
+	return func(client *GradioClient) error {
+		client.ja3 = true
+		return nil
+	}
+}
+
+func GradioHeaderHelper(key, value string) GradioHelper {
+	return func(client *GradioClient) error {
+		if key != "" {
+			client.headers[key] = value
+		}
+		return nil
+	}
+}
+
+func GradioCookiesHelper(cookies string) GradioHelper {
+	return func(client *GradioClient) error {
+		client.cookies = cookies
+		return nil
+	}
+}
+
+// 数据流断开后的重连次数，heartbeat > 0 时超时未收到心跳也会重连
+func GradioReconnectHelper(attempts int, heartbeat time.Duration) GradioHelper {
+	return func(client *GradioClient) error {
+		client.attempts = attempts
+		client.heartbeat = heartbeat
+		return nil
+	}
+}
+
+func GradioHashHelper(hash string) GradioHelper {
+	return func(client *GradioClient) error {
+		if hash == "" {
+			return errors.New("'hash' cannot be empty")
+		}
+		client.hash = hash
+		return nil
+	}
+}
+
+// 创建Gradio客户端，并拉取 /config、/info；src 可以是地址或 Hugging Face 的 space id（owner/name）
+func NewGradio(ctx context.Context, session *Session, src string, opts ...GradioHelper) (client *GradioClient, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	client = &GradioClient{
+		root:    strings.TrimSuffix(src, "/"),
+		hub:     "https://huggingface.co",
+		hash:    GioHash(),
+		headers: make(map[string]string),
+		session: session,
+
+		attempts: 3,
+	}
+
+	for _, exec := range opts {
+		if err = exec(client); err != nil {
+			return nil, err
+		}
+	}
+
+	if client.session == nil {
+		client.session, err = NewSession(client.proxies, false, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if IsSpaceId(src) {
+		client.root, err = client.resolveSpace(ctx, src)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err = client.fetchConfig(ctx); err != nil {
+		return nil, err
+	}
+
+	// 关闭了api文档的应用没有 /info，不影响调用
+	_ = client.fetchInfo(ctx)
+	return
+}
+
+func (c *GradioClient) Config() *GradioConfig {
+	return c.config
+}
+
+func (c *GradioClient) Info() *GradioInfo {
+	return c.info
+}
+
+func (c *GradioClient) Hash() string {
+	return c.hash
+}
+
+func (c *GradioClient) Protocol() string {
+	if c.config == nil || c.config.Protocol == "" {
+		return "ws"
+	}
+	return c.config.Protocol
+}
+
+// 关闭复用中的数据流
+func (c *GradioClient) Close() {
+	c.mu.Lock()
+	mux := c.mux
+	c.mu.Unlock()
+	if mux != nil {
+		mux.Close()
+	}
+}
+
+func (c *GradioClient) Cookies() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cookies
+}
+
+// 解析 api_name 或 fn_index 对应的端点
+func (c *GradioClient) Endpoint(name string) (*GradioEndpoint, error) {
+	if c.config == nil {
+		return nil, Error{-1, "Gradio", "", errors.New("config is nil")}
+	}
+
+	name = "/" + strings.TrimPrefix(name, "/")
+	index, convErr := strconv.Atoi(name[1:])
+	for i, dep := range c.config.Dependencies {
+		fnIndex := i
+		if dep.Id != nil {
+			fnIndex = *dep.Id
+		}
+
+		apiName := dep.name()
+		if (apiName == "" || apiName != name) && (convErr != nil || index != fnIndex) {
+			continue
+		}
+
+		endpoint := &GradioEndpoint{
+			Name:      apiName,
+			FnIndex:   fnIndex,
+			TriggerId: dep.triggerId(),
+			Queue:     c.queued(dep),
+		}
+
+		if c.info != nil {
+			if info, ok := c.info.NamedEndpoints[apiName]; ok && apiName != "" {
+				endpoint.Info = &info
+			} else if info, ok = c.info.UnnamedEndpoints[strconv.Itoa(fnIndex)]; ok {
+				endpoint.Info = &info
+			}
+		}
+		return endpoint, nil
+	}
+
+	return nil, Error{-1, "Gradio", "", fmt.Errorf("endpoint '%s' not found", name)}
+}
+
+// 同步执行，返回 process_completed 的输出
+func (c *GradioClient) Predict(ctx context.Context, endpoint string, args ...interface{}) ([]interface{}, error) {
+	job, err := c.Submit(ctx, endpoint, args...)
+	if err != nil {
+		return nil, err
+	}
+	return job.Wait()
+}
+
+// 提交任务，返回的job可注册事件后执行 Do / Wait
+func (c *GradioClient) Submit(ctx context.Context, endpoint string, args ...interface{}) (job *GradioJob, err error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	ep, err := c.Endpoint(endpoint)
+	if err != nil {
+		return
+	}
+
+	data, err := c.uploads(ctx, args)
+	if err != nil {
+		return
+	}
+
+	payload := map[string]interface{}{
+		"data":         data,
+		"event_data":   nil,
+		"fn_index":     ep.FnIndex,
+		"trigger_id":   ep.TriggerId,
+		"session_hash": c.hash,
+	}
+
+	job = &GradioJob{Endpoint: ep, client: c}
+	switch {
+	case !ep.Queue:
+		err = c.submitPredict(ctx, job, payload)
+	case c.Protocol() == "ws":
+		err = c.submitWs(ctx, job, payload)
+	case c.Protocol() == "sse":
+		err = c.submitSSE(ctx, job, payload)
+	default:
+		err = c.submitSSEv1(ctx, job, payload)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	job.OnCancel(job.cancel)
+	return
+}
+
+// 取消远端任务，释放队列位置；旧版本没有 /reset 时尝试 /cancel
+func (c *GradioClient) Reset(ctx context.Context, eventId string, fnIndex int) error {
+	payload := map[string]interface{}{
+		"event_id":     eventId,
+		"fn_index":     fnIndex,
+		"session_hash": c.hash,
+	}
+
+	response, err := c.builder(ctx).
+		POST(c.api("/reset")).
+		JSONHeader().
+		Body(payload).
+		DoS(http.StatusOK)
+	if err != nil {
+		var e Error
+		if !errors.As(err, &e) || e.Code != http.StatusNotFound {
+			return err
+		}
+
+		response, err = c.builder(ctx).
+			POST(c.api("/cancel")).
+			JSONHeader().
+			Body(payload).
+			DoS(http.StatusOK)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.mergeCookies(response)
+	return response.Body.Close()
+}
+
+func (c *GradioClient) submitWs(ctx context.Context, job *GradioJob, payload map[string]interface{}) error {
+	builder := SocketBuilder(c.session).
+		Context(ctx).
+		URL(c.wsURL("/queue/join"))
+	for k, v := range c.headers {
+		builder.Header(k, v)
+	}
+	if cookies := c.Cookies(); cookies != "" {
+		builder.Header("Cookie", cookies)
+	}
+
+	conn, response, err := builder.DoS(http.StatusSwitchingProtocols)
+	if err != nil {
+		// 握手失败时只返回 ErrBadHandshake，按响应的状态码转换
+		if response != nil && response.StatusCode != http.StatusSwitchingProtocols {
+			err = Status(http.StatusSwitchingProtocols)(response)
+		}
+		return gradioError(err)
+	}
+
+	e, err := NewGio(ctx, conn)
+	if err != nil {
+		return err
+	}
+	e.Protocol(c.Protocol())
+
+	e.Event("send_hash", func(j JoinEvent) interface{} {
+		return map[string]interface{}{
+			"fn_index":     payload["fn_index"],
+			"session_hash": payload["session_hash"],
+		}
+	})
+	e.Event("send_data", func(j JoinEvent) interface{} {
+		return payload
+	})
+
+	job.GioEmits = e
+	return nil
+}
+
+func (c *GradioClient) submitSSE(ctx context.Context, job *GradioJob, payload map[string]interface{}) error {
+	response, err := c.builder(ctx).
+		GET(c.api("/queue/join")).
+		Query("fn_index", strconv.Itoa(job.Endpoint.FnIndex)).
+		Query("session_hash", c.hash).
+		DoC(Status(http.StatusOK), IsSTREAM)
+	if err != nil {
+		return gradioError(err)
+	}
+	c.mergeCookies(response)
+
+	e, err := NewGio(ctx, response)
+	if err != nil {
+		return err
+	}
+	e.Protocol(c.Protocol())
+
+	e.Event("send_data", func(j JoinEvent) interface{} {
+		job.setEventId(j.EventId)
+		payload["event_id"] = j.EventId
+		r, err := c.builder(ctx).
+			POST(c.api("/queue/data")).
+			JSONHeader().
+			Body(payload).
+			DoS(http.StatusOK)
+		if err != nil {
+			e.Failed(gradioError(err))
+			return nil
+		}
+		c.mergeCookies(r)
+		_ = r.Body.Close()
+		return nil
+	})
+
+	job.GioEmits = e
+	return nil
+}
+
+func (c *GradioClient) submitSSEv1(ctx context.Context, job *GradioJob, payload map[string]interface{}) error {
+	response, err := c.builder(ctx).
+		POST(c.api("/queue/join")).
+		JSONHeader().
+		Body(payload).
+		DoS(http.StatusOK)
+	if err != nil {
+		return gradioError(err)
+	}
+	c.mergeCookies(response)
+
+	var obj struct {
+		EventId string `json:"event_id"`
+	}
+	err = ToObject(response, &obj)
+	_ = response.Body.Close()
+	if err != nil {
+		return Error{-1, "Gradio", "", err}
+	}
+	job.setEventId(obj.EventId)
+
+	events, release := c.multiplexer().Subscribe(obj.EventId)
+	e, err := NewGio(ctx, events)
+	if err != nil {
+		release()
+		return err
+	}
+
+	job.GioEmits = e
+	job.release = release
+	return nil
+}
+
+// 未开启队列的端点同步返回结果，包装为只含 process_completed 的事件流
+func (c *GradioClient) submitPredict(ctx context.Context, job *GradioJob, payload map[string]interface{}) error {
+	response, err := c.builder(ctx).
+		POST(c.api("/run/predict")).
+		JSONHeader().
+		Body(payload).
+		Do()
+	if err != nil {
+		return gradioError(err)
+	}
+	c.mergeCookies(response)
+
+	data, err := io.ReadAll(response.Body)
+	_ = response.Body.Close()
+	if err != nil {
+		return Error{-1, "Gradio", "", err}
+	}
+
+	var output JoinOutput
+	if err = json.Unmarshal(data, &output); err != nil || (response.StatusCode != http.StatusOK && output.Error == "") {
+		return gradioError(Error{response.StatusCode, "Gradio", string(data), errors.New(response.Status)})
+	}
+
+	events := make(chan JoinEvent, 1)
+	events <- JoinEvent{
+		Msg:          "process_completed",
+		Success:      response.StatusCode == http.StatusOK && output.Error == "",
+		Output:       &output,
+		InitialBytes: data,
+	}
+	close(events)
+
+	e, err := NewGio(ctx, events)
+	if err != nil {
+		return err
+	}
+	e.Protocol(c.Protocol())
+
+	job.GioEmits = e
+	return nil
+}
+
+func (c *GradioClient) multiplexer() *GioMux {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.mux == nil {
+		c.mux = NewGioMux(c.Protocol(), func(ctx context.Context) (*http.Response, error) {
+			response, err := c.builder(ctx).
+				GET(c.api("/queue/data")).
+				Query("session_hash", c.hash).
+				DoC(Status(http.StatusOK), IsSTREAM)
+			if err != nil {
+				return nil, err
+			}
+			c.mergeCookies(response)
+			return response, nil
+		})
+		c.mux.Reconnect(c.attempts, c.heartbeat)
+	}
+	return c.mux
+}
+
+func (job *GradioJob) cancel() {
+	if job.release != nil {
+		job.release()
+	}
+
+	// ws 断开连接即可取消
+	if job.conn != nil {
+		_ = job.conn.Close()
+		return
+	}
+
+	if job.response != nil {
+		_ = job.response.Body.Close()
+	}
+
+	eventId := job.eventId()
+	if eventId == "" {
+		return
+	}
+
+	// 远端取消在后台执行，不阻塞 Do / Cancel 返回
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = job.client.Reset(ctx, eventId, job.Endpoint.FnIndex)
+	}()
+}
+
+func (job *GradioJob) setEventId(eventId string) {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	job.EventId = eventId
+}
+
+func (job *GradioJob) eventId() string {
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	return job.EventId
+}
+
+func (job *GradioJob) Do() error {
+	if job.release != nil {
+		defer job.release()
+	}
+	return job.GioEmits.Do()
+}
+
+func (job *GradioJob) DoAsync() chan error {
+	err := make(chan error, 1)
+	go func() {
+		err <- job.Do()
+	}()
+	return err
+}
+
+// 等待任务结束，返回 process_completed 的输出
+func (job *GradioJob) Wait() ([]interface{}, error) {
+	output, err := job.Result()
+	if err != nil || output == nil {
+		return nil, err
+	}
+	return output.Data, nil
+}
+
+// 等待任务结束，失败时返回 output 中的 error、title
+func (job *GradioJob) Result() (*JoinOutput, error) {
+	err := job.Do()
+	j := job.Completed()
+	if j == nil {
+		if err == nil && job.release != nil {
+			err = job.client.multiplexer().Err()
+		}
+		if err == nil {
+			err = Error{-1, "Gradio", "", ErrGioStreamEnded}
+		}
+		return nil, err
+	}
+
+	if !j.Success {
+		title, msg := j.Title, string(j.InitialBytes)
+		if j.Output != nil {
+			if j.Output.Title != "" {
+				title = j.Output.Title
+			}
+			if j.Output.Error != "" {
+				msg = j.Output.Error
+			}
+		}
+		if isQuotaMessage(title) || isQuotaMessage(msg) {
+			return nil, Error{-1, "Gradio", msg, ErrGradioQuota}
+		}
+		if title == "" {
+			title = "process failed"
+		}
+		return nil, Error{-1, "Gradio", msg, errors.New(title)}
+	}
+	return j.Output, nil
+}
+
+func (c *GradioClient) builder(ctx context.Context) *Builder {
+	builder := ClientBuilder(c.session).
+		Context(ctx).
+		Proxies(c.proxies)
+	if c.ja3 {
+		builder.Ja3()
+	}
+	for k, v := range c.headers {
+		builder.Header(k, v)
+	}
+	if cookies := c.Cookies(); cookies != "" {
+		builder.Header("Cookie", cookies)
+	}
+	return builder
+}
+
+func (c *GradioClient) fetchConfig(ctx context.Context) error {
+	response, err := c.builder(ctx).
+		GET(c.root+"/config").
+		DoC(Status(http.StatusOK), IsJSON)
+	if err != nil {
+		return gradioError(err)
+	}
+	c.mergeCookies(response)
+	defer response.Body.Close()
+
+	var config GradioConfig
+	if err = ToObject(response, &config); err != nil {
+		return Error{-1, "Gradio", "", err}
+	}
+	c.config = &config
+	return nil
+}
+
+func (c *GradioClient) fetchInfo(ctx context.Context) error {
+	response, err := c.builder(ctx).
+		GET(c.api("/info")).
+		DoC(Status(http.StatusOK), IsJSON)
+	if err != nil {
+		return err
+	}
+	c.mergeCookies(response)
+	defer response.Body.Close()
+
+	var info GradioInfo
+	if err = ToObject(response, &info); err != nil {
+		return Error{-1, "Gradio", "", err}
+	}
+	c.info = &info
+	return nil
+}
+
+func (c *GradioClient) mergeCookies(response *http.Response) {
+	cookies := GetCookies(response)
+	if cookies == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cookies = MergeCookies(c.cookies, cookies)
+}
+
+func (c *GradioClient) api(path string) string {
+	prefix := ""
+	if c.config != nil {
+		prefix = strings.TrimSuffix(c.config.ApiPrefix, "/")
+	}
+	return c.root + prefix + path
+}
+
+func (c *GradioClient) wsURL(path string) string {
+	u := c.api(path)
+	if strings.HasPrefix(u, "https://") {
+		return "wss://" + u[8:]
+	}
+	if strings.HasPrefix(u, "http://") {
+		return "ws://" + u[7:]
+	}
+	return u
+}
+
+func (dep GradioDependency) name() string {
+	if name, ok := dep.ApiName.(string); ok && name != "" {
+		return "/" + strings.TrimPrefix(name, "/")
+	}
+	return ""
+}
+
+// 3.x 由 enable_queue 决定是否排队，4.x 起应用层总是开启队列
+func (c *GradioClient) queued(dep GradioDependency) bool {
+	if dep.Queue != nil && !*dep.Queue {
+		return false
+	}
+	return c.config.EnableQueue || c.config.Protocol != ""
+}
+
+// 3.x: [12]，4.x: [[12, "click"]]
+func (dep GradioDependency) triggerId() *int {
+	if len(dep.Targets) == 0 {
+		return nil
+	}
+
+	target := dep.Targets[0]
+	if slice, ok := target.([]interface{}); ok {
+		if len(slice) == 0 {
+			return nil
+		}
+		target = slice[0]
+	}
+
+	if id, ok := target.(float64); ok {
+		value := int(id)
+		return &value
+	}
+	return nil
+}
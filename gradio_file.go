@@ -0,0 +1,209 @@
+package emit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type GradioFile struct {
+	Path     string                 `json:"path"`
+	URL      string                 `json:"url,omitempty"`
+	Size     int64                  `json:"size,omitempty"`
+	OrigName string                 `json:"orig_name,omitempty"`
+	MimeType string                 `json:"mime_type,omitempty"`
+	IsStream bool                   `json:"is_stream,omitempty"`
+	Meta     map[string]interface{} `json:"meta,omitempty"`
+}
+
+// 待上传的文件，作为 Submit / Predict 的参数时会先上传到 /upload 再替换为 GradioFile
+type GradioUpload struct {
+	Name   string
+	Path   string
+	Reader io.Reader
+}
+
+func GradioFilePath(path string) *GradioUpload {
+	return &GradioUpload{Name: filepath.Base(path), Path: path}
+}
+
+func GradioFileReader(name string, reader io.Reader) *GradioUpload {
+	return &GradioUpload{Name: name, Reader: reader}
+}
+
+// 从输出中解析 FileData，非文件对象返回false
+func ParseGradioFile(value interface{}) (file GradioFile, ok bool) {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	file.Path, ok = obj["path"].(string)
+	if !ok {
+		return
+	}
+
+	file.URL, _ = obj["url"].(string)
+	file.OrigName, _ = obj["orig_name"].(string)
+	file.MimeType, _ = obj["mime_type"].(string)
+	file.IsStream, _ = obj["is_stream"].(bool)
+	file.Meta, _ = obj["meta"].(map[string]interface{})
+	if size, is := obj["size"].(float64); is {
+		file.Size = int64(size)
+	}
+	return
+}
+
+func (c *GradioClient) UploadFile(ctx context.Context, path string) (*GradioFile, error) {
+	return c.Upload(ctx, GradioFilePath(path))
+}
+
+// multipart 流式上传，不缓存整个文件
+func (c *GradioClient) Upload(ctx context.Context, upload *GradioUpload) (file *GradioFile, err error) {
+	if upload == nil {
+		return nil, Error{-1, "Gradio", "", errors.New("upload is nil")}
+	}
+
+	reader := upload.Reader
+	if reader == nil {
+		f, err := os.Open(upload.Path)
+		if err != nil {
+			return nil, Error{-1, "Gradio", "", err}
+		}
+		defer f.Close()
+		reader = f
+	}
+
+	name := upload.Name
+	if name == "" {
+		name = "file"
+	}
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	mw := multipart.NewWriter(pw)
+	sizeCh := make(chan int64, 1)
+	go func() {
+		var size int64
+		part, err := mw.CreateFormFile("files", name)
+		if err == nil {
+			size, err = io.Copy(part, reader)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		sizeCh <- size
+		_ = pw.CloseWithError(err)
+	}()
+
+	response, err := c.builder(ctx).
+		POST(c.api("/upload")).
+		Query("upload_id", GioHash()).
+		Header("Content-Type", mw.FormDataContentType()).
+		Buffer(pr).
+		DoC(Status(http.StatusOK), IsJSON)
+	_ = pr.Close()
+	size := <-sizeCh
+	if err != nil {
+		return nil, gradioError(err)
+	}
+	c.mergeCookies(response)
+	defer response.Body.Close()
+
+	var paths []string
+	if err = ToObject(response, &paths); err != nil {
+		return nil, Error{-1, "Gradio", "", err}
+	}
+	if len(paths) == 0 {
+		return nil, Error{-1, "Gradio", "", errors.New("upload returned no path")}
+	}
+
+	file = &GradioFile{
+		Path:     paths[0],
+		Size:     size,
+		OrigName: name,
+		MimeType: mime.TypeByExtension(filepath.Ext(name)),
+		Meta:     map[string]interface{}{"_type": "gradio.FileData"},
+	}
+	return
+}
+
+// 输出文件的下载地址，url 为空时拼接 /file=
+func (c *GradioClient) FileURL(file GradioFile) string {
+	if strings.HasPrefix(file.URL, "http://") || strings.HasPrefix(file.URL, "https://") {
+		return file.URL
+	}
+	if strings.HasPrefix(file.URL, "/") {
+		return c.root + file.URL
+	}
+	return c.api("/file=" + file.Path)
+}
+
+func (c *GradioClient) Open(ctx context.Context, file GradioFile) (io.ReadCloser, error) {
+	response, err := c.builder(ctx).
+		GET(c.FileURL(file)).
+		DoS(http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	c.mergeCookies(response)
+	return response.Body, nil
+}
+
+func (c *GradioClient) Download(ctx context.Context, file GradioFile, dst string) error {
+	reader, err := c.Open(ctx, file)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	f, err := os.Create(dst)
+	if err != nil {
+		return Error{-1, "Gradio", "", err}
+	}
+
+	if _, err = io.Copy(f, reader); err != nil {
+		_ = f.Close()
+		return Error{-1, "Gradio", "", err}
+	}
+	return f.Close()
+}
+
+// 递归替换参数中的 GradioUpload
+func (c *GradioClient) uploads(ctx context.Context, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case *GradioUpload:
+		return c.Upload(ctx, v)
+	case GradioUpload:
+		return c.Upload(ctx, &v)
+	case []interface{}:
+		slice := make([]interface{}, len(v))
+		for i, item := range v {
+			result, err := c.uploads(ctx, item)
+			if err != nil {
+				return nil, err
+			}
+			slice[i] = result
+		}
+		return slice, nil
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result, err := c.uploads(ctx, item)
+			if err != nil {
+				return nil, err
+			}
+			obj[key] = result
+		}
+		return obj, nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,805 @@
+package emit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/RomiChan/websocket"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type gradioServer struct {
+	*httptest.Server
+	streams int32
+	events  int32
+	files   sync.Map
+	resets  chan string
+	auth    sync.Map
+	full    atomic.Bool
+	quota   atomic.Bool
+
+	// 3.x 关闭 enable_queue，所有端点走 /run/predict
+	noQueue atomic.Bool
+
+	// sse 协议：event_id 对应 POST /queue/data 提交的 data
+	datas sync.Map
+
+	// /reset 挂起直到 hold 关闭
+	slow atomic.Bool
+	hold chan struct{}
+
+	// 数据流发送一条消息后断开 / 挂起的次数
+	drops  atomic.Int32
+	stalls atomic.Int32
+}
+
+func newGradioServer(t *testing.T, protocol string, events func(eventId string, data []interface{}) []string) *gradioServer {
+	return newGradioServerN(t, protocol, 1, events)
+}
+
+// jobs: 数据流在收到对应数量的 process_completed 后结束
+func newGradioServerN(t *testing.T, protocol string, jobs int, events func(eventId string, data []interface{}) []string) *gradioServer {
+	server := &gradioServer{resets: make(chan string, 8), hold: make(chan struct{})}
+	pending := make(chan string, 64)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		config := map[string]interface{}{
+			"version":  "4.36.0",
+			"protocol": protocol,
+			"dependencies": []interface{}{
+				map[string]interface{}{"id": 0, "api_name": false, "targets": [][]interface{}{{3, "click"}}},
+				map[string]interface{}{"id": 1, "api_name": "chat", "targets": [][]interface{}{{5, "submit"}}},
+				map[string]interface{}{"id": 2, "api_name": "fast", "targets": [][]interface{}{{7, "click"}}, "queue": false},
+			},
+		}
+		// 3.x 没有 protocol 字段，默认为 ws
+		if protocol == "ws" {
+			config["version"] = "3.50.2"
+			config["enable_queue"] = !server.noQueue.Load()
+			delete(config, "protocol")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(config)
+	})
+	mux.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"named_endpoints":{"/chat":{"parameters":[{"label":"message","parameter_name":"message"}],"returns":[]}},"unnamed_endpoints":{}}`))
+	})
+	mux.HandleFunc("/run/predict", func(w http.ResponseWriter, r *http.Request) {
+		var obj map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&obj); err != nil {
+			t.Error(err)
+		}
+		if obj["session_hash"] == "" || obj["fn_index"] == nil {
+			t.Errorf("unexpected predict payload: %v", obj)
+		}
+
+		data, _ := obj["data"].([]interface{})
+		w.Header().Set("Content-Type", "application/json")
+		if len(data) > 0 && data[0] == "fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"error":"predict failed"}`))
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"data":["echo: %v"],"duration":0.1}`, data[0])
+	})
+	mux.HandleFunc("/queue/join", func(w http.ResponseWriter, r *http.Request) {
+		if server.full.Load() {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"detail":"Queue is full."}`))
+			return
+		}
+
+		switch protocol {
+		case "ws":
+			server.joinWs(t, w, r, events)
+			return
+		case "sse":
+			server.joinSSE(t, w, r, events)
+			return
+		}
+
+		var obj map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&obj); err != nil {
+			t.Error(err)
+		}
+
+		data, _ := obj["data"].([]interface{})
+		eventId := fmt.Sprintf("evt-%v-%d", obj["fn_index"], atomic.AddInt32(&server.events, 1))
+		for _, line := range events(eventId, data) {
+			pending <- line
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"event_id":"%s"}`, eventId)
+	})
+	mux.HandleFunc("/queue/data", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			server.postData(w, r)
+			return
+		}
+
+		if r.URL.Query().Get("session_hash") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		atomic.AddInt32(&server.streams, 1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		completed := 0
+		for completed < jobs {
+			var line string
+			select {
+			case line = <-pending:
+			case <-r.Context().Done():
+				return
+			}
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", line)
+			w.(http.Flusher).Flush()
+			if strings.Contains(line, "process_completed") {
+				completed++
+			}
+
+			if server.drops.Add(-1) >= 0 {
+				return
+			}
+			if server.stalls.Add(-1) >= 0 {
+				<-r.Context().Done()
+				return
+			}
+		}
+
+		for {
+			select {
+			case line := <-pending:
+				_, _ = fmt.Fprintf(w, "data: %s\n\n", line)
+			default:
+				return
+			}
+		}
+	})
+	mux.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) {
+		var obj map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&obj)
+		server.resets <- fmt.Sprint(obj["event_id"])
+		if server.slow.Load() {
+			select {
+			case <-server.hold:
+			case <-r.Context().Done():
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"success":true}`))
+	})
+	mux.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		if server.quota.Load() {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+
+		f, header, err := r.FormFile("files")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+
+		bin, _ := io.ReadAll(f)
+		path := "/tmp/gradio/" + header.Filename
+		server.files.Store(path, bin)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode([]string{path})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		bin, ok := server.files.Load(strings.TrimPrefix(r.URL.Path, "/file="))
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(bin.([]byte))
+	})
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.auth.Store(r.URL.Path, r.Header.Get("Authorization"))
+		mux.ServeHTTP(w, r)
+	}))
+	return server
+}
+
+// ws 协议：send_hash、send_data 握手后推送事件
+func (server *gradioServer) joinWs(t *testing.T, w http.ResponseWriter, r *http.Request, events func(eventId string, data []interface{}) []string) {
+	upgrader := websocket.Upgrader{}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	var hash, payload map[string]interface{}
+	if err = conn.WriteJSON(map[string]string{"msg": "send_hash"}); err == nil {
+		err = conn.ReadJSON(&hash)
+	}
+	if err == nil {
+		err = conn.WriteJSON(map[string]string{"msg": "send_data"})
+	}
+	if err == nil {
+		err = conn.ReadJSON(&payload)
+	}
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if hash["session_hash"] == "" || hash["session_hash"] != payload["session_hash"] || hash["fn_index"] != payload["fn_index"] {
+		t.Errorf("unexpected handshake: %v %v", hash, payload)
+		return
+	}
+
+	data, _ := payload["data"].([]interface{})
+	eventId := fmt.Sprintf("evt-%v-%d", payload["fn_index"], atomic.AddInt32(&server.events, 1))
+	for _, line := range events(eventId, data) {
+		if err = conn.WriteMessage(websocket.TextMessage, []byte(line)); err != nil {
+			return
+		}
+	}
+}
+
+// sse 协议：GET /queue/join 推送 send_data，收到 POST /queue/data 后继续推送事件
+func (server *gradioServer) joinSSE(t *testing.T, w http.ResponseWriter, r *http.Request, events func(eventId string, data []interface{}) []string) {
+	if r.Method != http.MethodGet || r.URL.Query().Get("session_hash") == "" {
+		t.Errorf("unexpected join: %s %s", r.Method, r.URL)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	eventId := fmt.Sprintf("evt-%s-%d", r.URL.Query().Get("fn_index"), atomic.AddInt32(&server.events, 1))
+	ch := make(chan []interface{}, 1)
+	server.datas.Store(eventId, ch)
+	defer server.datas.Delete(eventId)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	_, _ = fmt.Fprintf(w, "data: {\"msg\":\"send_data\",\"event_id\":\"%s\"}\n\n", eventId)
+	w.(http.Flusher).Flush()
+
+	var data []interface{}
+	select {
+	case data = <-ch:
+	case <-r.Context().Done():
+		return
+	}
+
+	for _, line := range events(eventId, data) {
+		_, _ = fmt.Fprintf(w, "data: %s\n\n", line)
+		w.(http.Flusher).Flush()
+	}
+}
+
+func (server *gradioServer) postData(w http.ResponseWriter, r *http.Request) {
+	var obj map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&obj)
+
+	eventId, _ := obj["event_id"].(string)
+	ch, ok := server.datas.Load(eventId)
+	if !ok || obj["session_hash"] == nil || obj["fn_index"] == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	data, _ := obj["data"].([]interface{})
+	ch.(chan []interface{}) <- data
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write([]byte(`{"msg":"success"}`))
+}
+
+func TestGradioPredict(t *testing.T) {
+	server := newGradioServer(t, "sse_v1", func(eventId string, data []interface{}) []string {
+		return []string{
+			fmt.Sprintf(`{"msg":"estimation","event_id":"%s","rank":0,"queue_size":1}`, eventId),
+			fmt.Sprintf(`{"msg":"process_starts","event_id":"%s"}`, eventId),
+			fmt.Sprintf(`{"msg":"process_completed","event_id":"%s","success":true,"output":{"data":["echo: %v"]}}`, eventId, data[0]),
+		}
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := NewGradio(ctx, nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint, err := client.Endpoint("/chat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint.FnIndex != 1 || endpoint.TriggerId == nil || *endpoint.TriggerId != 5 || endpoint.Info == nil {
+		t.Fatalf("unexpected endpoint: %+v", endpoint)
+	}
+
+	data, err := client.Predict(ctx, "/chat", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 || data[0] != "echo: hi" {
+		t.Fatalf("unexpected output: %v", data)
+	}
+}
+
+func TestGradioProtocols(t *testing.T) {
+	for _, protocol := range []string{"ws", "sse"} {
+		server := newGradioServer(t, protocol, func(eventId string, data []interface{}) []string {
+			return []string{
+				fmt.Sprintf(`{"msg":"process_starts","event_id":"%s"}`, eventId),
+				fmt.Sprintf(`{"msg":"process_generating","event_id":"%s","success":true,"output":{"data":["echo"]}}`, eventId),
+				fmt.Sprintf(`{"msg":"process_completed","event_id":"%s","success":true,"output":{"data":["echo: %v"]}}`, eventId, data[0]),
+			}
+		})
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		client, err := NewGradio(ctx, nil, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if client.Protocol() != protocol {
+			t.Fatalf("unexpected protocol: %s", client.Protocol())
+		}
+
+		job, err := client.Submit(ctx, "/chat", "hi")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var generating int
+		job.Event("process_generating", func(j JoinEvent) interface{} {
+			generating++
+			return nil
+		})
+
+		data, err := job.Wait()
+		if err != nil {
+			t.Fatalf("%s: %v", protocol, err)
+		}
+		if len(data) != 1 || data[0] != "echo: hi" || generating != 1 {
+			t.Fatalf("%s: unexpected output: %v", protocol, data)
+		}
+
+		// sse 的 event_id 来自 send_data
+		if protocol == "sse" && job.EventId != "evt-1-1" {
+			t.Fatalf("unexpected event id: %s", job.EventId)
+		}
+
+		server.full.Store(true)
+		if _, err = client.Submit(ctx, "/chat", "hi"); !errors.Is(err, ErrGradioQueueFull) {
+			t.Fatalf("%s: unexpected error: %v", protocol, err)
+		}
+	}
+}
+
+func TestGradioPredictNoQueue(t *testing.T) {
+	for _, protocol := range []string{"ws", "sse_v3"} {
+		server := newGradioServer(t, protocol, func(eventId string, data []interface{}) []string {
+			return []string{
+				fmt.Sprintf(`{"msg":"process_completed","event_id":"%s","success":true,"output":{"data":["queued: %v"]}}`, eventId, data[0]),
+			}
+		})
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		client, err := NewGradio(ctx, nil, server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		endpoint, err := client.Endpoint("/fast")
+		if err != nil || endpoint.Queue {
+			t.Fatalf("%s: unexpected endpoint: %+v %v", protocol, endpoint, err)
+		}
+
+		data, err := client.Predict(ctx, "/fast", "hi")
+		if err != nil || len(data) != 1 || data[0] != "echo: hi" {
+			t.Fatalf("%s: unexpected output: %v %v", protocol, data, err)
+		}
+
+		// 其它端点仍走队列
+		data, err = client.Predict(ctx, "/chat", "hi")
+		if err != nil || len(data) != 1 || data[0] != "queued: hi" {
+			t.Fatalf("%s: unexpected output: %v %v", protocol, data, err)
+		}
+
+		_, err = client.Predict(ctx, "/fast", "fail")
+		var e Error
+		if !errors.As(err, &e) || e.Msg != "predict failed" {
+			t.Fatalf("%s: unexpected error: %v", protocol, err)
+		}
+	}
+
+	// 3.x 关闭 enable_queue 时所有端点都不排队
+	server := newGradioServer(t, "ws", func(eventId string, data []interface{}) []string {
+		t.Error("unexpected queue join")
+		return nil
+	})
+	defer server.Close()
+	server.noQueue.Store(true)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := NewGradio(ctx, nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := client.Predict(ctx, "/chat", "hi")
+	if err != nil || len(data) != 1 || data[0] != "echo: hi" {
+		t.Fatalf("unexpected output: %v %v", data, err)
+	}
+}
+
+func TestGradioPredictFailed(t *testing.T) {
+	server := newGradioServer(t, "sse_v1", func(eventId string, _ []interface{}) []string {
+		return []string{
+			fmt.Sprintf(`{"msg":"process_completed","event_id":"%s","success":false,"output":{"error":"boom"}}`, eventId),
+		}
+	})
+	defer server.Close()
+
+	client, err := NewGradio(context.Background(), nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Predict(context.Background(), "0")
+	var e Error
+	if !errors.As(err, &e) || e.Msg != "boom" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGradioStreamEnded(t *testing.T) {
+	server := newGradioServer(t, "sse", func(eventId string, _ []interface{}) []string {
+		return []string{
+			fmt.Sprintf(`{"msg":"process_starts","event_id":"%s"}`, eventId),
+			`{"msg":"close_stream"}`,
+		}
+	})
+	defer server.Close()
+
+	client, err := NewGradio(context.Background(), nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = client.Predict(context.Background(), "/chat", "hi"); !errors.Is(err, ErrGioStreamEnded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGradioDiffStream(t *testing.T) {
+	server := newGradioServer(t, "sse_v3", func(eventId string, _ []interface{}) []string {
+		return []string{
+			`{"msg":"heartbeat"}`,
+			fmt.Sprintf(`{"msg":"process_generating","event_id":"%s","success":true,"output":{"data":[[["user","Hel"]], {"a":[1]}]}}`, eventId),
+			fmt.Sprintf(`{"msg":"process_generating","event_id":"%s","success":true,"output":{"data":[[["append",[0,1],"lo"]], [["add",["a",0],0],["replace",["b"],true]]]}}`, eventId),
+			fmt.Sprintf(`{"msg":"process_generating","event_id":"%s","success":true,"output":{"data":[[["add",[1],["bot","hi"]]], [["delete",["b"],null]]]}}`, eventId),
+			fmt.Sprintf(`{"msg":"process_completed","event_id":"%s","success":true,"output":{"data":[[["user","Hello"],["bot","hi"]], {"a":[0,1]}]}}`, eventId),
+			`{"msg":"close_stream"}`,
+		}
+	})
+	defer server.Close()
+
+	client, err := NewGradio(context.Background(), nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := client.Submit(context.Background(), "/chat", "Hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var generating []string
+	job.Event("process_generating", func(j JoinEvent) interface{} {
+		bin, _ := json.Marshal(j.Output.Data)
+		generating = append(generating, string(bin))
+		return nil
+	})
+
+	if _, err = job.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		`[[["user","Hel"]],{"a":[1]}]`,
+		`[[["user","Hello"]],{"a":[0,1],"b":true}]`,
+		`[[["user","Hello"],["bot","hi"]],{"a":[0,1]}]`,
+	}
+	if fmt.Sprint(generating) != fmt.Sprint(expected) {
+		t.Fatalf("unexpected outputs:\n%v\n%v", generating, expected)
+	}
+}
+
+func TestGradioMultiplex(t *testing.T) {
+	server := newGradioServerN(t, "sse_v3", 2, func(eventId string, data []interface{}) []string {
+		return []string{
+			fmt.Sprintf(`{"msg":"process_starts","event_id":"%s"}`, eventId),
+			fmt.Sprintf(`{"msg":"process_completed","event_id":"%s","success":true,"output":{"data":["%v"]}}`, eventId, data[0]),
+		}
+	})
+	defer server.Close()
+
+	client, err := NewGradio(context.Background(), nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	jobs := make([]*GradioJob, 0)
+	for _, query := range []string{"a", "b"} {
+		job, err := client.Submit(context.Background(), "/chat", query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	var wg sync.WaitGroup
+	results := make([]interface{}, len(jobs))
+	for i, job := range jobs {
+		wg.Add(1)
+		go func(i int, job *GradioJob) {
+			defer wg.Done()
+			data, err := job.Wait()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			results[i] = data[0]
+		}(i, job)
+	}
+	wg.Wait()
+
+	if results[0] != "a" || results[1] != "b" {
+		t.Fatalf("unexpected results: %v", results)
+	}
+	if streams := atomic.LoadInt32(&server.streams); streams != 1 {
+		t.Fatalf("expected one shared stream, got %d", streams)
+	}
+}
+
+func TestGradioMuxPending(t *testing.T) {
+	m := NewGioMux("sse_v3", func(ctx context.Context) (*http.Response, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	defer m.Close()
+
+	// 退订后的事件不再缓存
+	_, release := m.Subscribe("a")
+	release()
+	m.dispatch(JoinEvent{Msg: "progress", EventId: "a"})
+	m.dispatch(JoinEvent{Msg: "process_completed", EventId: "a"})
+	m.dispatch(JoinEvent{Msg: "progress", EventId: "b"})
+
+	m.mu.Lock()
+	if _, ok := m.pending["a"]; ok || len(m.pending) != 1 {
+		t.Fatalf("unexpected pending: %v", m.pending)
+	}
+
+	// 过期清理
+	expired := time.Now().Add(-2 * gioPendingTTL)
+	m.pending["b"].at = expired
+	m.finished["a"] = expired
+	m.swept = time.Time{}
+	m.mu.Unlock()
+
+	m.dispatch(JoinEvent{Msg: "progress", EventId: "c"})
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.pending["c"]; !ok || len(m.pending) != 1 || len(m.finished) != 0 {
+		t.Fatalf("unexpected state: %v %v", m.pending, m.finished)
+	}
+}
+
+func TestGradioFile(t *testing.T) {
+	server := newGradioServer(t, "sse_v3", func(eventId string, data []interface{}) []string {
+		bin, _ := json.Marshal(data[0])
+		return []string{
+			fmt.Sprintf(`{"msg":"process_completed","event_id":"%s","success":true,"output":{"data":[%s]}}`, eventId, bin),
+		}
+	})
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "input.txt")
+	if err := os.WriteFile(path, []byte("hello gradio"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	client, err := NewGradio(ctx, nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	data, err := client.Predict(ctx, "/chat", GradioFilePath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, ok := ParseGradioFile(data[0])
+	if !ok {
+		t.Fatalf("not a file: %v", data[0])
+	}
+	if file.Path != "/tmp/gradio/input.txt" || file.OrigName != "input.txt" || file.Size != 12 {
+		t.Fatalf("unexpected file: %+v", file)
+	}
+
+	dst := filepath.Join(t.TempDir(), "output.txt")
+	if err = client.Download(ctx, file, dst); err != nil {
+		t.Fatal(err)
+	}
+
+	bin, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(bin) != "hello gradio" {
+		t.Fatalf("unexpected content: %s", bin)
+	}
+}
+
+func TestGradioDecodeOutput(t *testing.T) {
+	var output JoinOutput
+	err := json.Unmarshal([]byte(`{"data":[[["hi","hello"]],"ignored",{"path":"/tmp/a.png","url":"/file=/tmp/a.png"},3]}`), &output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		History [][]string
+		Image   GradioFile `gio:"2"`
+		Count   int
+		Skip    string `gio:"-"`
+	}
+
+	value, err := DecodeJoin[result](&output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.History[0][1] != "hello" || value.Image.Path != "/tmp/a.png" || value.Count != 3 {
+		t.Fatalf("unexpected value: %+v", value)
+	}
+
+	var j JoinEvent
+	err = json.Unmarshal([]byte(`{"msg":"progress","event_id":"x","progress_data":[{"index":1,"length":10,"unit":"steps","progress":null,"desc":null}]}`), &j)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(j.ProgressData) != 1 || *j.ProgressData[0].Length != 10 || j.ProgressData[0].Progress != nil {
+		t.Fatalf("unexpected progress: %+v", j.ProgressData)
+	}
+}
+
+func TestGradioCancel(t *testing.T) {
+	server := newGradioServer(t, "sse_v3", func(eventId string, _ []interface{}) []string {
+		return []string{
+			fmt.Sprintf(`{"msg":"process_starts","event_id":"%s"}`, eventId),
+		}
+	})
+	defer server.Close()
+	defer close(server.hold)
+	server.slow.Store(true)
+
+	client, err := NewGradio(context.Background(), nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	job, err := client.Submit(ctx, "/chat", "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 远端 /reset 未返回时也不阻塞
+	start := time.Now()
+	_, err = job.Wait()
+	if !errors.Is(err, ErrGioCancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("cancel blocked for %v", elapsed)
+	}
+
+	select {
+	case eventId := <-server.resets:
+		if eventId != job.EventId {
+			t.Fatalf("unexpected reset: %s", eventId)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("reset not called")
+	}
+}
+
+func TestGradioReconnect(t *testing.T) {
+	events := func(eventId string, _ []interface{}) []string {
+		return []string{
+			fmt.Sprintf(`{"msg":"process_starts","event_id":"%s"}`, eventId),
+			fmt.Sprintf(`{"msg":"process_generating","event_id":"%s","success":true,"output":{"data":["a"]}}`, eventId),
+			fmt.Sprintf(`{"msg":"process_generating","event_id":"%s","success":true,"output":{"data":[[["append",[],"b"]]]}}`, eventId),
+			fmt.Sprintf(`{"msg":"process_completed","event_id":"%s","success":true,"output":{"data":["ab"]}}`, eventId),
+		}
+	}
+
+	t.Run("recover", func(t *testing.T) {
+		server := newGradioServer(t, "sse_v3", events)
+		defer server.Close()
+		server.drops.Store(1)
+		server.stalls.Store(1)
+
+		client, err := NewGradio(context.Background(), nil, server.URL, GradioReconnectHelper(3, 200*time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		job, err := client.Submit(context.Background(), "/chat", "hi")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var generating []interface{}
+		job.Event("process_generating", func(j JoinEvent) interface{} {
+			generating = append(generating, j.Output.Data[0])
+			return nil
+		})
+
+		data, err := job.Wait()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data[0] != "ab" || fmt.Sprint(generating) != "[a ab]" {
+			t.Fatalf("unexpected output: %v %v", data, generating)
+		}
+		if streams := atomic.LoadInt32(&server.streams); streams != 3 {
+			t.Fatalf("expected 3 streams, got %d", streams)
+		}
+	})
+
+	t.Run("exhausted", func(t *testing.T) {
+		server := newGradioServer(t, "sse_v3", events)
+		defer server.Close()
+		server.drops.Store(10)
+
+		client, err := NewGradio(context.Background(), nil, server.URL, GradioReconnectHelper(1, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+
+		if _, err = client.Predict(context.Background(), "/chat", "hi"); !errors.Is(err, ErrGioStreamEnded) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
@@ -0,0 +1,102 @@
+package emit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	// 队列已满：queue_full 消息或 /queue/join 返回 503
+	ErrGradioQueueFull = errors.New("gradio queue full")
+	// 额度耗尽：429 或 ZeroGPU 的 quota 提示
+	ErrGradioQuota = errors.New("gradio quota exceeded")
+)
+
+var hfSpaceRegexp = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+
+// Hugging Face 访问令牌，http 与 websocket 请求都会携带；私有空间、ZeroGPU 额度都依赖它
+func GradioTokenHelper(token string) GradioHelper {
+	return func(client *GradioClient) error {
+		if token == "" {
+			return nil
+		}
+		if !strings.HasPrefix(token, "hf_") {
+			return errors.New("invalid hugging face token, it must start with 'hf_'")
+		}
+		client.headers["Authorization"] = "Bearer " + token
+		return nil
+	}
+}
+
+// 解析 space id 使用的 hub 地址，默认 https://huggingface.co
+func GradioHubHelper(endpoint string) GradioHelper {
+	return func(client *GradioClient) error {
+		client.hub = strings.TrimSuffix(endpoint, "/")
+		return nil
+	}
+}
+
+func IsSpaceId(src string) bool {
+	return !strings.Contains(src, "://") && hfSpaceRegexp.MatchString(src)
+}
+
+// owner/name => https://owner-name.hf.space
+func SpaceHost(space string) string {
+	subdomain := strings.ToLower(space)
+	subdomain = strings.NewReplacer("/", "-", "_", "-", ".", "-").Replace(subdomain)
+	return "https://" + subdomain + ".hf.space"
+}
+
+// 通过 /api/spaces/{id}/host 获取空间地址，接口不可用时按命名规则推导
+func (c *GradioClient) resolveSpace(ctx context.Context, space string) (string, error) {
+	response, err := c.builder(ctx).
+		GET(fmt.Sprintf("%s/api/spaces/%s/host", c.hub, space)).
+		DoC(Status(http.StatusOK), IsJSON)
+	if err != nil {
+		var e Error
+		if errors.As(err, &e) {
+			switch e.Code {
+			case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+				return "", Error{e.Code, "Gradio", e.Msg, fmt.Errorf("space '%s' not found, private spaces require a token", space)}
+			}
+		}
+		return SpaceHost(space), nil
+	}
+	defer response.Body.Close()
+
+	var obj struct {
+		Host string `json:"host"`
+	}
+	if err = ToObject(response, &obj); err != nil || obj.Host == "" {
+		return SpaceHost(space), nil
+	}
+	return strings.TrimSuffix(obj.Host, "/"), nil
+}
+
+// 将排队、额度相关的状态码转换为对应错误
+func gradioError(err error) error {
+	var e Error
+	if !errors.As(err, &e) {
+		return err
+	}
+
+	switch e.Code {
+	case http.StatusServiceUnavailable:
+		e.Err = ErrGradioQueueFull
+	case http.StatusTooManyRequests:
+		e.Err = ErrGradioQuota
+	default:
+		return err
+	}
+	e.Bus = "Gradio"
+	return e
+}
+
+func isQuotaMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	return strings.Contains(msg, "quota") && strings.Contains(msg, "exceeded")
+}
@@ -0,0 +1,97 @@
+package emit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSpaceHost(t *testing.T) {
+	if !IsSpaceId("tonyassi/text-to-image-SDXL") || IsSpaceId("https://chat.lmsys.org") || IsSpaceId("a/b/c") {
+		t.Fatal("unexpected space id detection")
+	}
+
+	if host := SpaceHost("tonyassi/text-to-image-SDXL"); host != "https://tonyassi-text-to-image-sdxl.hf.space" {
+		t.Fatalf("unexpected host: %s", host)
+	}
+}
+
+func TestGradioSpace(t *testing.T) {
+	const token = "hf_test"
+	server := newGradioServer(t, "sse_v3", func(eventId string, _ []interface{}) []string {
+		return []string{
+			fmt.Sprintf(`{"msg":"process_completed","event_id":"%s","success":false,"title":"ZeroGPU quota exceeded","output":{"error":"You have exceeded your GPU quota (60s left vs. 90s requested)."}}`, eventId),
+		}
+	})
+	defer server.Close()
+
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/spaces/owner/private-space/host" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"subdomain":"owner-private-space","host":"%s"}`, server.URL)
+	}))
+	defer hub.Close()
+
+	ctx := context.Background()
+	if _, err := NewGradio(ctx, nil, "owner/private-space", GradioHubHelper(hub.URL)); err == nil {
+		t.Fatal("expected error without token")
+	}
+
+	client, err := NewGradio(ctx, nil, "owner/private-space", GradioHubHelper(hub.URL), GradioTokenHelper(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if _, err = client.Predict(ctx, "/chat", "hi"); !errors.Is(err, ErrGradioQuota) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, path := range []string{"/config", "/queue/join", "/queue/data"} {
+		if value, _ := server.auth.Load(path); value != "Bearer "+token {
+			t.Fatalf("missing authorization on %s: %v", path, value)
+		}
+	}
+
+	server.full.Store(true)
+	if _, err = client.Submit(ctx, "/chat", "hi"); !errors.Is(err, ErrGradioQueueFull) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestGradioErrors(t *testing.T) {
+	unavailable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer unavailable.Close()
+
+	ctx := context.Background()
+	if _, err := NewGradio(ctx, nil, unavailable.URL); !errors.Is(err, ErrGradioQueueFull) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	server := newGradioServer(t, "sse_v3", func(string, []interface{}) []string { return nil })
+	defer server.Close()
+
+	client, err := NewGradio(ctx, nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	server.quota.Store(true)
+	if _, err = client.Upload(ctx, GradioFileReader("a.txt", strings.NewReader("a"))); !errors.Is(err, ErrGradioQuota) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
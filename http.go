@@ -2,15 +2,12 @@ package emit
 
 import (
 	"bytes"
-	"compress/flate"
 	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"github.com/RomiChan/websocket"
-	"github.com/andybalholm/brotli"
 	fhttp "github.com/bogdanfinn/fhttp"
 	"github.com/bogdanfinn/tls-client"
 	"github.com/bogdanfinn/tls-client/profiles"
@@ -58,6 +55,14 @@ type Builder struct {
 	fetchWithes func() []string
 
 	encoding []string
+	parts    []formPart
+	params   map[string]string
+
+	retry    *RetryPolicy
+	attempts int
+
+	middlewares []Middleware
+	override    bool
 }
 
 type Session struct {
@@ -67,15 +72,16 @@ type Session struct {
 	client    *http.Client
 	tlsClient tls_client.HttpClient
 	dialer    *websocket.Dialer
+
+	base        *url.URL
+	retry       *RetryPolicy
+	middlewares []Middleware
+	headers     map[string]string
+	encoding    []string
 }
 
 type OptionHelper = func(proxies string, redirect bool, session *Session) error
 
-type readCloser struct {
-	io.Reader
-	io.Closer
-}
-
 func TLSHandshakeTimeoutHelper(timeout time.Duration) OptionHelper {
 	return func(_ string, _ bool, session *Session) error {
 		if session.opts == nil {
@@ -169,7 +175,7 @@ func Ja3Helper(echo Echo, timeout int) OptionHelper {
 			options = append(options, tls_client.WithRandomTLSExtensionOrder())
 		}
 
-		c, err := tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+		c, err := newTlsClient(options...)
 		if err != nil {
 			return err
 		}
@@ -179,6 +185,13 @@ func Ja3Helper(echo Echo, timeout int) OptionHelper {
 	}
 }
 
+// 关闭 tls-client 自带的解压，统一由 DecodeBody 处理：
+// 其 http2 实现解压 deflate 时会在读循环中同步读取整个 body，导致请求阻塞
+func newTlsClient(options ...tls_client.HttpClientOption) (tls_client.HttpClient, error) {
+	options = append(options, tls_client.WithTransportOptions(&tls_client.TransportOptions{DisableCompression: true}))
+	return tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+}
+
 func NewSession(proxies string, redirect bool, withes func() []string, opts ...OptionHelper) (session *Session, err error) {
 	session = &Session{}
 	for _, exec := range opts {
@@ -217,7 +230,7 @@ func NewSession(proxies string, redirect bool, withes func() []string, opts ...O
 			options = append(options, tls_client.WithProxyUrl(proxies))
 		}
 
-		session.tlsClient, err = tls_client.NewHttpClient(tls_client.NewNoopLogger(), options...)
+		session.tlsClient, err = newTlsClient(options...)
 		if err != nil {
 			return
 		}
@@ -333,7 +346,7 @@ func (c *Builder) Query(key, value string) *Builder {
 	if key == "" {
 		return c
 	}
-	c.query = append(c.query, fmt.Sprintf("%s=%s", key, value))
+	c.query = append(c.query, queryPair(key, value))
 	return c
 }
 
@@ -383,14 +396,23 @@ func (c *Builder) DoC(funs ...func(*http.Response) error) (*http.Response, error
 				response.Body = io.NopCloser(bytes.NewBufferString(value))
 				_ = response.Body.Close()
 			}
-			return response, err
+			return response, withAttempts(err, c.attempts)
 		}
 	}
 
 	return response, nil
 }
 
+// 执行请求，设置了重试策略时按策略重试
 func (c *Builder) Do() (*http.Response, error) {
+	if policy := c.retryPolicy(); policy != nil && policy.Attempts > 1 && c.err == nil {
+		return c.doRetry(policy)
+	}
+	c.attempts = 1
+	return c.do()
+}
+
+func (c *Builder) do() (*http.Response, error) {
 	if c.err != nil {
 		return nil, c.err
 	}
@@ -418,65 +440,29 @@ func (c *Builder) Do() (*http.Response, error) {
 		session = cli
 	}
 
-	query := ""
-	if len(c.query) > 0 {
-		var slice []string
-		for _, value := range c.query {
-			slice = append(slice, value)
-		}
-		query = "?" + strings.Join(slice, "&")
+	u, err := composeURL(c.session, c.url, c.params, c.query)
+	if err != nil {
+		return nil, Error{-1, "Do", "", err}
 	}
 
 	if c.jar != nil {
 		session.Jar = c.jar
 	}
 
-	if c.buffer == nil {
-		c.buffer = bytes.NewBuffer(c.bytes)
-	}
-
-	request, err := http.NewRequest(c.method, c.url+query, c.buffer)
+	request, err := c.newRequest(u)
 	if err != nil {
-		return nil, Error{-1, "Do", "", err}
-	}
-
-	h := request.Header
-	for k, v := range c.headers {
-		h.Add(k, v)
-	}
-
-	if c.ctx != nil {
-		request = request.WithContext(c.ctx)
+		return nil, err
 	}
 
-	response, err := session.Do(request)
+	response, err := c.chain(session.Do)(request)
 	if err != nil {
-		err = Error{-1, "Do", "", err}
-	}
-
-	if len(c.encoding) > 0 {
-		encoding := response.Header.Get("Content-Encoding")
-		switch encoding {
-		case "gzip":
-			if slices.Contains(c.encoding, "gzip") {
-				response.Body, err = DecodeGZip(response.Body)
-				if err != nil {
-					return response, Error{-1, "Do decoding/gzip", "", err}
-				}
-			}
-		case "deflate":
-			if slices.Contains(c.encoding, "deflate") {
-				response.Body = flate.NewReader(response.Body)
-			}
-		case "br":
-			if slices.Contains(c.encoding, "br") {
-				response.Body = &readCloser{brotli.NewReader(response.Body), response.Body}
-			}
-		}
+		return nil, err
 	}
 
-	_ = request.Body.Close()
-	return response, err
+	if encodings := c.encodings(); len(encodings) > 0 {
+		DecodeBody(response, encodings...)
+	}
+	return response, nil
 }
 
 func (c *Builder) doJ() (*http.Response, error) {
@@ -492,36 +478,12 @@ func (c *Builder) doJ() (*http.Response, error) {
 		}
 	}
 
-	query := ""
-	if len(c.query) > 0 {
-		var slice []string
-		for _, value := range c.query {
-			slice = append(slice, value)
-		}
-		query = "?" + strings.Join(slice, "&")
-	}
-
-	if len(c.bytes) == 0 && c.buffer != nil {
-		data, err := io.ReadAll(c.buffer)
-		if err != nil {
-			return nil, Error{-1, "Do", "", err}
-		}
-		c.bytes = data
-	}
-
-	request, err := fhttp.NewRequest(c.method, c.url+query, bytes.NewReader(c.bytes))
+	u, err := composeURL(c.session, c.url, c.params, c.query)
 	if err != nil {
 		return nil, Error{-1, "Do", "", err}
 	}
 
 	if c.jar != nil {
-		var u *url.URL
-
-		u, err = url.Parse(c.url)
-		if err != nil {
-			return nil, Error{-1, "Do", "", err}
-		}
-
 		cookies := c.jar.Cookies(u)
 		var newCookies []*fhttp.Cookie
 		for _, cookie := range cookies {
@@ -533,16 +495,88 @@ func (c *Builder) doJ() (*http.Response, error) {
 		c.session.tlsClient.SetCookies(u, newCookies)
 	}
 
-	request.Header = fhttp.Header{}
-	for k, v := range c.headers {
-		request.Header.Set(k, v)
+	request, err := c.newRequest(u)
+	if err != nil {
+		return nil, err
 	}
 
-	response, err := c.session.tlsClient.Do(request)
+	response, err := c.chain(c.sendJ)(request)
 	if err != nil {
+		return nil, err
+	}
+
+	if encodings := c.encodings(); len(encodings) > 0 {
+		DecodeBody(response, encodings...)
+	}
+	return response, nil
+}
+
+// std 与 Ja3 共用的请求，不回写 c.buffer，保证同一个 Builder 可以重复执行
+func (c *Builder) newRequest(u *url.URL) (*http.Request, error) {
+	body := c.buffer
+	if body == nil {
+		body = bytes.NewReader(c.bytes)
+	}
+
+	contentType := ""
+	if len(c.parts) > 0 {
+		body, contentType = c.multipart()
+	}
+
+	request, err := http.NewRequest(c.method, u.String(), body)
+	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			_ = closer.Close()
+		}
 		return nil, Error{-1, "Do", "", err}
 	}
 
+	h := request.Header
+	for k, v := range c.headers {
+		h.Add(k, v)
+	}
+	if c.session != nil {
+		for k, v := range c.session.headers {
+			if !c.hasHeader(k) {
+				h.Set(k, v)
+			}
+		}
+	}
+	if contentType != "" {
+		h.Set("Content-Type", contentType)
+	}
+	if encodings := c.encodings(); len(encodings) > 0 && h.Get("Accept-Encoding") == "" {
+		h.Set("Accept-Encoding", strings.Join(encodings, ", "))
+	}
+
+	if c.ctx != nil {
+		request = request.WithContext(c.ctx)
+	}
+	return request, nil
+}
+
+// 转换为 fhttp 的请求经 tls-client 发送
+func (c *Builder) sendJ(request *http.Request) (*http.Response, error) {
+	var body io.Reader
+	if request.Body != nil && request.Body != http.NoBody {
+		body = request.Body
+	}
+
+	req, err := fhttp.NewRequestWithContext(request.Context(), request.Method, request.URL.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = fhttp.Header(request.Header.Clone())
+	req.ContentLength = request.ContentLength
+	if request.Host != "" && request.Host != request.URL.Host {
+		req.Host = request.Host
+	}
+
+	response, err := c.session.tlsClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
 	headers := response.Header
 	newHeaders := http.Header{}
 	for k, _ := range headers {
@@ -562,9 +596,17 @@ func (c *Builder) doJ() (*http.Response, error) {
 		Close:            response.Close,
 		Uncompressed:     response.Uncompressed,
 		Trailer:          (map[string][]string)(response.Trailer),
+		Request:          request,
 	}
 
-	return &r, err
+	if len(c.encodings()) > 0 {
+		// 未关闭解压的 tls-client 在 http2 下会按 Content-Encoding 自动解压，但不会删除该头
+		encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+		if r.Uncompressed && slices.Contains([]string{"gzip", "br", "deflate"}, encoding) {
+			r.Header.Del("Content-Encoding")
+		}
+	}
+	return &r, nil
 }
 
 func client(proxies string, redirect bool, withes func() []string, option *ConnectOption) (*http.Client, error) {
@@ -669,23 +711,57 @@ func client(proxies string, redirect bool, withes func() []string, option *Conne
 }
 
 func ToObject(response *http.Response, obj interface{}) (err error) {
+	defer response.Body.Close()
 	var data []byte
 	data, err = io.ReadAll(response.Body)
 	if err != nil {
 		return
 	}
 
-	// encoding := response.Header.Get("Content-Encoding")
-	// if encoding != "" && response.Proto == "JA3" {
-	//	if IsEncoding(data, encoding) {
-	//		requests.DecompressBody(&data, encoding)
-	//	}
-	// }
-
 	err = json.Unmarshal(data, obj)
 	return
 }
 
+// 解码 JSON 响应体到 T，结束后关闭 body；解码失败时 Error.Msg 携带截断后的响应内容
+func Decode[T any](response *http.Response) (value T, err error) {
+	if response == nil {
+		err = Error{-1, "Decode", "", errors.New("response is nil")}
+		return
+	}
+
+	defer response.Body.Close()
+	data, err := io.ReadAll(response.Body)
+	if err != nil {
+		err = Error{-1, "Decode", snippet(data), err}
+		return
+	}
+
+	if err = json.Unmarshal(data, &value); err != nil {
+		err = Error{-1, "Decode", snippet(data), err}
+	}
+	return
+}
+
+// 执行请求并校验 conditions，然后解码为 T；任何情况下都会关闭 body
+func DoJSON[T any](c *Builder, conditions ...func(*http.Response) error) (value T, err error) {
+	response, err := c.DoC(conditions...)
+	if err != nil {
+		if response != nil && response.Body != nil {
+			_ = response.Body.Close()
+		}
+		return
+	}
+	return Decode[T](response)
+}
+
+func snippet(data []byte) string {
+	const limit = 512
+	if len(data) <= limit {
+		return string(data)
+	}
+	return strings.ToValidUTF8(string(data[:limit]), "") + "..."
+}
+
 func ToMap(response *http.Response) (obj map[string]interface{}, err error) {
 	err = ToObject(response, &obj)
 	return
@@ -802,25 +878,15 @@ func TextResponse(response *http.Response) (value string) {
 	if response == nil {
 		return
 	}
-	bin, err := io.ReadAll(response.Body)
+	reader, err := TextReader(response)
 	if err != nil {
 		return
 	}
 
-	// encoding := response.Header.Get("Content-Encoding")
-	// if encoding != "" && response.Proto == "JA3" {
-	//	if IsEncoding(bin, encoding) {
-	//		requests.DecompressBody(&bin, encoding)
-	//	}
-	// }
+	bin, err := io.ReadAll(reader)
+	if err != nil {
+		return
+	}
 
 	return string(bin)
 }
-
-// func Decode(data *[]byte, encoding string) {
-//	if encoding != "" && data != nil {
-//		if IsEncoding(*data, encoding) {
-//			requests.DecompressBody(data, encoding)
-//		}
-//	}
-// }
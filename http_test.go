@@ -2,8 +2,13 @@ package emit
 
 import (
 	"context"
+	"errors"
 	"github.com/bogdanfinn/tls-client/profiles"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
 )
 
@@ -98,3 +103,66 @@ func TestEncoding(t *testing.T) {
 	t.Logf("ja3_hash: %s", obj["ja3_hash"])
 	t.Logf("user_agent: %s", obj["user_agent"])
 }
+
+type closeCounter struct {
+	io.ReadCloser
+	closed *atomic.Int32
+}
+
+func (c closeCounter) Close() error {
+	c.closed.Add(1)
+	return c.ReadCloser.Close()
+}
+
+func TestDoJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/broken" {
+			_, _ = w.Write([]byte("{\"name\": " + strings.Repeat("x", 1024)))
+			return
+		}
+		_, _ = w.Write([]byte(`{"name":"emit","tags":["a","b"]}`))
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		Name string   `json:"name"`
+		Tags []string `json:"tags"`
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).GET(server.URL)
+		if ja3 {
+			builder.Ja3()
+		}
+
+		value, err := DoJSON[result](builder, Status(http.StatusOK), IsJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value.Name != "emit" || len(value.Tags) != 2 {
+			t.Fatalf("ja3=%v unexpected value: %+v", ja3, value)
+		}
+	}
+
+	_, err = DoJSON[result](ClientBuilder(session).GET(server.URL+"/broken"), Status(http.StatusOK))
+	var e Error
+	if !errors.As(err, &e) || e.Bus != "Decode" || len(e.Msg) != 515 || !strings.HasSuffix(e.Msg, "...") {
+		t.Fatalf("expected decode Error with snippet, got %v", err)
+	}
+
+	response, err := ClientBuilder(session).GET(server.URL).DoS(http.StatusOK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var closed atomic.Int32
+	response.Body = closeCounter{response.Body, &closed}
+	if _, err = Decode[map[string]interface{}](response); err != nil || closed.Load() != 1 {
+		t.Fatalf("expected body closed once, got %d (%v)", closed.Load(), err)
+	}
+}
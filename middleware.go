@@ -0,0 +1,111 @@
+package emit
+
+import (
+	"errors"
+	"net/http"
+)
+
+// 执行请求，std 与 Ja3 均以 net/http 的类型传递
+type Handler func(request *http.Request) (*http.Response, error)
+
+// 中间件：可在调用 next 之前修改请求、之后处理响应，不调用 next 时直接返回自定义响应，
+// 多次调用 next 即为重试：有 GetBody 的请求体（Bytes、Body 等）会自动重置，
+// Buffer、multipart 等只能读取一次的请求体重试时返回错误
+type Middleware func(next Handler) Handler
+
+// session 级别的中间件，按顺序由外到内执行
+func MiddlewareHelper(middlewares ...Middleware) OptionHelper {
+	return func(_ string, _ bool, session *Session) error {
+		session.middlewares = append(session.middlewares, middlewares...)
+		return nil
+	}
+}
+
+// 追加中间件，在 session 的中间件之内执行
+func (c *Builder) Use(middlewares ...Middleware) *Builder {
+	c.middlewares = append(c.middlewares, middlewares...)
+	return c
+}
+
+// 替换 session 的中间件，不传参数时不执行任何中间件
+func (c *Builder) Middlewares(middlewares ...Middleware) *Builder {
+	c.middlewares = middlewares
+	c.override = true
+	return c
+}
+
+// 发送前修改请求，返回错误时中断
+func Before(funcCall func(request *http.Request) error) Middleware {
+	return func(next Handler) Handler {
+		return func(request *http.Request) (*http.Response, error) {
+			if err := funcCall(request); err != nil {
+				return nil, err
+			}
+			return next(request)
+		}
+	}
+}
+
+// 收到响应后处理，返回错误时关闭 body 并中断
+func After(funcCall func(response *http.Response) error) Middleware {
+	return func(next Handler) Handler {
+		return func(request *http.Request) (*http.Response, error) {
+			response, err := next(request)
+			if err != nil {
+				return response, err
+			}
+			if err = funcCall(response); err != nil {
+				_ = response.Body.Close()
+				return nil, err
+			}
+			return response, nil
+		}
+	}
+}
+
+func (c *Builder) chain(send Handler) Handler {
+	var middlewares []Middleware
+	if !c.override && c.session != nil {
+		middlewares = append(middlewares, c.session.middlewares...)
+	}
+	middlewares = append(middlewares, c.middlewares...)
+
+	calls := 0
+	handler := func(request *http.Request) (*http.Response, error) {
+		calls++
+		if calls > 1 && request.Body != nil && request.Body != http.NoBody {
+			if request.GetBody == nil {
+				return nil, errors.New("request body cannot be replayed")
+			}
+			body, err := request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			request.Body = body
+		}
+		return send(request)
+	}
+
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+
+	return func(request *http.Request) (*http.Response, error) {
+		response, err := handler(request)
+		// 中间件没有发送请求时关闭请求体，multipart 的写入协程随之退出
+		if calls == 0 && request.Body != nil {
+			_ = request.Body.Close()
+		}
+		if err != nil {
+			if response != nil && response.Body != nil {
+				_ = response.Body.Close()
+			}
+			var e Error
+			if !errors.As(err, &e) {
+				err = Error{-1, "Do", "", err}
+			}
+			return nil, err
+		}
+		return response, nil
+	}
+}
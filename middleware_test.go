@@ -0,0 +1,217 @@
+package emit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		count.Add(1)
+		_, _ = w.Write([]byte(r.Header.Get("X-Trace") + "|" + string(body)))
+	}))
+	defer server.Close()
+
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(request *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				request.Header.Set("X-Trace", request.Header.Get("X-Trace")+name)
+				return next(request)
+			}
+		}
+	}
+
+	// 401 时刷新凭证后重试
+	token := "stale"
+	refresh := func(next Handler) Handler {
+		return func(request *http.Request) (*http.Response, error) {
+			request.Header.Set("Authorization", "Bearer "+token)
+			response, err := next(request)
+			if err != nil || response.StatusCode != http.StatusUnauthorized {
+				return response, err
+			}
+			_ = response.Body.Close()
+			token = "fresh"
+			request.Header.Set("Authorization", "Bearer "+token)
+			return next(request)
+		}
+	}
+
+	session, err := NewSession("", false, nil, MiddlewareHelper(trace("a"), refresh))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		order, token = nil, "stale"
+		builder := ClientBuilder(session).
+			POST(server.URL).
+			Bytes([]byte("payload")).
+			Use(trace("b"))
+		if ja3 {
+			builder.Ja3()
+		}
+
+		response, err := builder.DoS(http.StatusOK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value := TextResponse(response); value != "abb|payload" {
+			t.Fatalf("ja3=%v unexpected response: %q", ja3, value)
+		}
+		if strings.Join(order, ",") != "a,b,b" {
+			t.Fatalf("ja3=%v unexpected order: %v", ja3, order)
+		}
+	}
+	if count.Load() != 2 {
+		t.Fatalf("unexpected request count: %d", count.Load())
+	}
+}
+
+func TestMiddlewareShortCircuit(t *testing.T) {
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+	}))
+	defer server.Close()
+
+	cached := func(next Handler) Handler {
+		return func(request *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(`{"cached":true}`)),
+				Request:    request,
+			}, nil
+		}
+	}
+	denied := errors.New("denied")
+
+	session, err := NewSession("", false, nil, MiddlewareHelper(Before(func(*http.Request) error {
+		return denied
+	})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).GET(server.URL)
+		if ja3 {
+			builder.Ja3()
+		}
+		if _, err = builder.Do(); !errors.Is(err, denied) {
+			t.Fatalf("ja3=%v expected denied, got %v", ja3, err)
+		}
+
+		builder = ClientBuilder(session).GET(server.URL).Middlewares(cached)
+		if ja3 {
+			builder.Ja3()
+		}
+		value, err := DoJSON[map[string]bool](builder, IsJSON)
+		if err != nil || !value["cached"] {
+			t.Fatalf("ja3=%v unexpected response: %v %v", ja3, value, err)
+		}
+
+		builder = ClientBuilder(session).GET(server.URL).Middlewares(After(func(response *http.Response) error {
+			if response.StatusCode != http.StatusOK {
+				return errors.New("unexpected status")
+			}
+			return nil
+		}))
+		if ja3 {
+			builder.Ja3()
+		}
+		if _, err = builder.DoS(http.StatusOK); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if count.Load() != 2 {
+		t.Fatalf("unexpected request count: %d", count.Load())
+	}
+}
+
+func TestMiddlewareBuffer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).
+			POST(server.URL).
+			Buffer(strings.NewReader("hello")).
+			Use(Before(func(*http.Request) error { return nil }))
+		if ja3 {
+			builder.Ja3()
+		}
+
+		response, err := builder.DoS(http.StatusOK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value := TextResponse(response); value != "hello" {
+			t.Fatalf("ja3=%v unexpected response: %q", ja3, value)
+		}
+
+		// 只能读取一次的请求体不能重试
+		builder = ClientBuilder(session).
+			POST(server.URL).
+			Buffer(io.MultiReader(strings.NewReader("hello"))).
+			Use(func(next Handler) Handler {
+				return func(request *http.Request) (*http.Response, error) {
+					response, err := next(request)
+					if err != nil {
+						return nil, err
+					}
+					_ = response.Body.Close()
+					return next(request)
+				}
+			})
+		if ja3 {
+			builder.Ja3()
+		}
+		if _, err = builder.Do(); err == nil {
+			t.Fatalf("ja3=%v expected replay error", ja3)
+		}
+
+		// 未发送的 multipart 请求体被关闭
+		var body io.ReadCloser
+		builder = ClientBuilder(session).
+			POST(server.URL).
+			FileReader("file", "a.txt", strings.NewReader("hello")).
+			Use(func(next Handler) Handler {
+				return func(request *http.Request) (*http.Response, error) {
+					body = request.Body
+					return nil, errors.New("denied")
+				}
+			})
+		if ja3 {
+			builder.Ja3()
+		}
+		if _, err = builder.Do(); err == nil {
+			t.Fatalf("ja3=%v expected error", ja3)
+		}
+		if _, err = body.Read(make([]byte, 1)); !errors.Is(err, io.ErrClosedPipe) {
+			t.Fatalf("ja3=%v body not closed: %v", ja3, err)
+		}
+	}
+}
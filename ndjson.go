@@ -0,0 +1,109 @@
+package emit
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// 单个 JSON 值的默认最大长度
+const NDJSONMaxLineSize = 1024 * 1024
+
+// 流式解码 NDJSON / JSON-lines 响应：每行一个值，或多个 JSON 值直接拼接，边到达边解码。
+// ctx 取消后会关闭 body 以打断阻塞中的读取
+type NDJSONDecoder[T any] struct {
+	ctx     context.Context
+	body    io.ReadCloser
+	reader  *ndjsonReader
+	decoder *json.Decoder
+	stop    func() bool
+	err     error
+}
+
+type ndjsonReader struct {
+	reader  io.Reader
+	read    int64
+	offset  func() int64
+	maxSize int
+}
+
+func NewNDJSON[T any](ctx context.Context, response *http.Response) *NDJSONDecoder[T] {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	reader := &ndjsonReader{reader: response.Body, maxSize: NDJSONMaxLineSize}
+	decoder := json.NewDecoder(reader)
+	reader.offset = decoder.InputOffset
+
+	return &NDJSONDecoder[T]{
+		ctx:     ctx,
+		body:    response.Body,
+		reader:  reader,
+		decoder: decoder,
+		stop:    context.AfterFunc(ctx, func() { _ = response.Body.Close() }),
+	}
+}
+
+// 修改单个值的最大长度，需在第一次 Next 之前调用
+func (d *NDJSONDecoder[T]) MaxLineSize(size int) *NDJSONDecoder[T] {
+	d.reader.maxSize = size
+	return d
+}
+
+// 读取下一个值，流结束时返回 io.EOF
+func (d *NDJSONDecoder[T]) Next() (value T, err error) {
+	if d.err != nil {
+		return value, d.err
+	}
+
+	if err = d.decoder.Decode(&value); err != nil {
+		if ctxErr := d.ctx.Err(); ctxErr != nil {
+			err = Error{-1, "NDJSON", "", ctxErr}
+		} else if err != io.EOF {
+			err = Error{-1, "NDJSON", "", err}
+		}
+		d.err = err
+	}
+	return
+}
+
+func (d *NDJSONDecoder[T]) Close() error {
+	d.stop()
+	return d.body.Close()
+}
+
+// 逐个回调解码后的值，回调返回false时停止；结束后关闭 body
+func ReadNDJSON[T any](ctx context.Context, response *http.Response, funcCall func(value T) bool) error {
+	d := NewNDJSON[T](ctx, response)
+	defer d.Close()
+	for {
+		value, err := d.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !funcCall(value) {
+			return nil
+		}
+	}
+}
+
+// decoder 只有在当前值不完整时才会继续读取，已读未解码的部分即当前值的长度
+func (r *ndjsonReader) Read(p []byte) (n int, err error) {
+	pending := r.read - r.offset()
+	if pending > int64(r.maxSize) {
+		return 0, bufio.ErrTooLong
+	}
+
+	if limit := int64(r.maxSize) - pending + 1; int64(len(p)) > limit {
+		p = p[:limit]
+	}
+	n, err = r.reader.Read(p)
+	r.read += int64(n)
+	return
+}
@@ -0,0 +1,113 @@
+package emit
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNDJSON(t *testing.T) {
+	type line struct {
+		Id   int    `json:"id"`
+		Text string `json:"text"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, chunk := range []string{
+			"{\"id\":1,\"text\":\"a\"}\n",
+			"\n{\"id\":2,",
+			"\"text\":\"b\"}\r\n",
+			"{\"id\":3,\"text\":\"c\"}{\"id\":4,\"text\":\"d\"}\n",
+		} {
+			_, _ = w.Write([]byte(chunk))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).GET(server.URL)
+		if ja3 {
+			builder.Ja3()
+		}
+
+		response, err := builder.DoS(http.StatusOK)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var values []string
+		err = ReadNDJSON(context.Background(), response, func(value line) bool {
+			values = append(values, value.Text)
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(values, ",") != "a,b,c,d" {
+			t.Fatalf("ja3=%v unexpected values: %v", ja3, values)
+		}
+	}
+}
+
+func TestNDJSONMaxLineSize(t *testing.T) {
+	body := "{\"a\":1}\n{\"a\":\"" + strings.Repeat("x", 64) + "\"}\n"
+	d := NewNDJSON[map[string]interface{}](context.Background(), &http.Response{Body: io.NopCloser(strings.NewReader(body))}).
+		MaxLineSize(32)
+	defer d.Close()
+
+	if _, err := d.Next(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := d.Next()
+	if !errors.Is(err, bufio.ErrTooLong) {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestNDJSONCancel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{\"a\":1}\n"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	response, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d := NewNDJSON[map[string]int](ctx, response)
+	defer d.Close()
+
+	value, err := d.Next()
+	if err != nil || value["a"] != 1 {
+		t.Fatalf("unexpected value: %v %v", value, err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = d.Next()
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
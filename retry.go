@@ -0,0 +1,258 @@
+package emit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 默认可重试的状态码
+var RetryStatuses = []int{
+	http.StatusRequestTimeout,
+	http.StatusTooEarly,
+	http.StatusTooManyRequests,
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// 重试策略：间隔为 Backoff * 2^(n-1)，上限 MaxBackoff，并按 Jitter 比例随机浮动；
+// 响应携带 Retry-After 时以其为准，超过 MaxBackoff 则不再重试直接返回该响应。
+//
+// 默认只重试幂等的请求（GET、HEAD、OPTIONS、PUT、DELETE）；POST、PATCH 等只在连接被拒绝
+// （请求未到达服务端）时重试，避免重复提交，需要时通过 Retryable 自行判断
+type RetryPolicy struct {
+	// 最大尝试次数（含首次），小于等于1不重试
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// 0~1
+	Jitter float64
+	// 为空时使用 RetryStatuses
+	Statuses []int
+	// 自定义是否重试，设置后忽略 Statuses 与默认的网络异常判断
+	Retryable func(response *http.Response, err error) bool
+}
+
+// 重试耗尽后记录在 Error.Err 中
+type RetryError struct {
+	Attempts int
+	Err      error
+}
+
+func (err *RetryError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", err.Err, err.Attempts)
+}
+
+func (err *RetryError) Unwrap() error {
+	return err.Err
+}
+
+// 尝试次数，未经过重试时为1
+func (err Error) Attempts() int {
+	var re *RetryError
+	if errors.As(err.Err, &re) {
+		return re.Attempts
+	}
+	return 1
+}
+
+// session 默认的重试策略
+func RetryHelper(policy RetryPolicy) OptionHelper {
+	return func(_ string, _ bool, session *Session) error {
+		session.retry = &policy
+		return nil
+	}
+}
+
+// 覆盖 session 的重试策略，Attempts 小于等于1时关闭重试
+func (c *Builder) Retry(policy RetryPolicy) *Builder {
+	c.retry = &policy
+	return c
+}
+
+func (c *Builder) retryPolicy() *RetryPolicy {
+	if c.retry != nil {
+		return c.retry
+	}
+	if c.session != nil {
+		return c.session.retry
+	}
+	return nil
+}
+
+func (c *Builder) doRetry(policy *RetryPolicy) (*http.Response, error) {
+	if err := c.replayable(); err != nil {
+		return nil, err
+	}
+
+	ctx := c.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	for attempt := 1; ; attempt++ {
+		c.attempts = attempt
+		response, err := c.do()
+		if attempt >= policy.Attempts || !policy.retryable(c.method, response, err) || ctx.Err() != nil {
+			return response, withAttempts(err, attempt)
+		}
+
+		delay := policy.backoff(attempt)
+		if response != nil {
+			if after, ok := retryAfter(response); ok {
+				if after > policy.maxBackoff() {
+					return response, nil
+				}
+				delay = after
+			}
+			_ = response.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, withAttempts(Error{-1, "Do", "", ctx.Err()}, attempt)
+		case <-timer.C:
+		}
+	}
+}
+
+// Buffer 与 reader 形式的 multipart 字段只能读取一次，重试前先缓存
+func (c *Builder) replayable() error {
+	if c.buffer != nil {
+		data, err := io.ReadAll(c.buffer)
+		if err != nil {
+			return Error{-1, "Do", "", err}
+		}
+		c.bytes, c.buffer = data, nil
+	}
+
+	for i, part := range c.parts {
+		if part.reader == nil {
+			continue
+		}
+		data, err := io.ReadAll(part.reader)
+		if err != nil {
+			return Error{-1, "Do", "", err}
+		}
+		c.parts[i].value, c.parts[i].reader = data, nil
+	}
+	return nil
+}
+
+func (policy *RetryPolicy) retryable(method string, response *http.Response, err error) bool {
+	if policy.Retryable != nil {
+		return policy.Retryable(response, err)
+	}
+
+	if !idempotent(method) {
+		return err != nil && errors.Is(err, syscall.ECONNREFUSED)
+	}
+
+	if err != nil {
+		return retryableError(err)
+	}
+
+	statuses := policy.Statuses
+	if len(statuses) == 0 {
+		statuses = RetryStatuses
+	}
+	return response != nil && slices.Contains(statuses, response.StatusCode)
+}
+
+func (policy *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := policy.Backoff
+	if delay <= 0 {
+		delay = 500 * time.Millisecond
+	}
+
+	for i := 1; i < attempt && delay < policy.maxBackoff(); i++ {
+		delay *= 2
+	}
+	delay = min(delay, policy.maxBackoff())
+
+	if jitter := min(max(policy.Jitter, 0), 1); jitter > 0 {
+		delay += time.Duration(float64(delay) * jitter * (rand.Float64()*2 - 1))
+	}
+	return delay
+}
+
+func (policy *RetryPolicy) maxBackoff() time.Duration {
+	if policy.MaxBackoff <= 0 {
+		return 30 * time.Second
+	}
+	return policy.MaxBackoff
+}
+
+// 网络层的超时、连接重置、连接被拒绝以及意外断开；
+// 协议不支持、证书校验失败等 url.Error 不重试
+func retryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	// url.Error 本身实现了 net.Error，需先取出内部错误
+	var ue *url.Error
+	if errors.As(err, &ue) {
+		err = ue.Err
+	}
+
+	var ne net.Error
+	return (errors.As(err, &ne) && ne.Timeout()) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+func idempotent(method string) bool {
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// 支持秒数与 HTTP 日期两种格式
+func retryAfter(response *http.Response) (time.Duration, bool) {
+	value := response.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+func withAttempts(err error, attempts int) error {
+	if err == nil || attempts <= 1 {
+		return err
+	}
+
+	var e Error
+	if !errors.As(err, &e) {
+		return Error{-1, "Do", "", &RetryError{attempts, err}}
+	}
+	e.Err = &RetryError{attempts, e.Err}
+	return e
+}
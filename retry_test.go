@@ -0,0 +1,211 @@
+package emit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if n := count.Add(1); n%3 != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil, RetryHelper(RetryPolicy{Attempts: 3, Backoff: time.Millisecond}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		count.Store(0)
+		builder := ClientBuilder(session).
+			Method(http.MethodPut).
+			URL(server.URL).
+			Buffer(strings.NewReader("replayed body"))
+		if ja3 {
+			builder.Ja3()
+		}
+
+		response, err := builder.DoS(http.StatusOK)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if value := TextResponse(response); value != "replayed body" || count.Load() != 3 {
+			t.Fatalf("ja3=%v unexpected response: %q after %d attempts", ja3, value, count.Load())
+		}
+	}
+
+	// 次数耗尽后，Error 中记录尝试次数
+	count.Store(0)
+	_, err = ClientBuilder(session).
+		GET(server.URL).
+		Retry(RetryPolicy{Attempts: 2, Backoff: time.Millisecond}).
+		DoS(http.StatusOK)
+
+	var e Error
+	if !errors.As(err, &e) || e.Code != http.StatusServiceUnavailable || e.Attempts() != 2 || count.Load() != 2 {
+		t.Fatalf("expected 503 after 2 attempts, got %v (%d)", err, count.Load())
+	}
+
+	// 非幂等请求默认不重试，需自定义判断
+	count.Store(0)
+	_, err = ClientBuilder(session).POST(server.URL).Bytes([]byte("once")).DoS(http.StatusOK)
+	if !errors.As(err, &e) || e.Attempts() != 1 || count.Load() != 1 {
+		t.Fatalf("expected single POST attempt, got %v (%d)", err, count.Load())
+	}
+
+	count.Store(0)
+	response, err := ClientBuilder(session).
+		POST(server.URL).
+		Bytes([]byte("twice")).
+		Retry(RetryPolicy{Attempts: 3, Backoff: time.Millisecond, Retryable: func(response *http.Response, err error) bool {
+			return err == nil && response.StatusCode == http.StatusServiceUnavailable
+		}}).
+		DoS(http.StatusOK)
+	if err != nil || TextResponse(response) != "twice" || count.Load() != 3 {
+		t.Fatalf("unexpected POST retry: %v (%d)", err, count.Load())
+	}
+
+	// 关闭重试
+	count.Store(0)
+	_, err = ClientBuilder(session).GET(server.URL).Retry(RetryPolicy{}).DoS(http.StatusOK)
+	if !errors.As(err, &e) || e.Attempts() != 1 || count.Load() != 1 {
+		t.Fatalf("expected single attempt, got %v (%d)", err, count.Load())
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var count atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count.Add(1)
+		w.Header().Set("Retry-After", r.URL.Query().Get("after"))
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policy := RetryPolicy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 2 * time.Second}
+
+	// 超过 MaxBackoff 直接返回
+	response, err := ClientBuilder(session).GET(server.URL).Query("after", "60").Retry(policy).Do()
+	if err != nil || response.StatusCode != http.StatusTooManyRequests || count.Load() != 1 {
+		t.Fatalf("unexpected retry: %v (%d)", err, count.Load())
+	}
+	_ = response.Body.Close()
+
+	count.Store(0)
+	start := time.Now()
+	response, err = ClientBuilder(session).GET(server.URL).Query("after", "1").Retry(policy).Do()
+	if err != nil || count.Load() != 3 {
+		t.Fatalf("unexpected retry: %v (%d)", err, count.Load())
+	}
+	_ = response.Body.Close()
+	if elapsed := time.Since(start); elapsed < 2*time.Second {
+		t.Fatalf("Retry-After not honored: %v", elapsed)
+	}
+}
+
+func TestRetryNetwork(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	addr := server.URL
+	server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).
+			GET(addr).
+			Retry(RetryPolicy{Attempts: 3, Backoff: time.Millisecond})
+		if ja3 {
+			builder.Ja3()
+		}
+
+		_, err = builder.Do()
+		var e Error
+		if !errors.As(err, &e) || e.Attempts() != 3 {
+			t.Fatalf("ja3=%v expected 3 attempts, got %v", ja3, err)
+		}
+	}
+
+	// 连接被拒绝时请求未到达服务端，非幂等请求也会重试
+	_, err = ClientBuilder(session).
+		POST(addr).
+		Retry(RetryPolicy{Attempts: 3, Backoff: time.Millisecond}).
+		Do()
+	var e Error
+	if !errors.As(err, &e) || e.Attempts() != 3 {
+		t.Fatalf("expected 3 POST attempts, got %v", err)
+	}
+
+	// 非网络异常的 url.Error 不重试
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).
+			GET("ftp" + strings.TrimPrefix(addr, "http")).
+			Retry(RetryPolicy{Attempts: 3, Backoff: time.Millisecond})
+		if ja3 {
+			builder.Ja3()
+		}
+
+		_, err = builder.Do()
+		var e Error
+		if !errors.As(err, &e) || e.Attempts() != 1 {
+			t.Fatalf("ja3=%v expected single attempt, got %v", ja3, err)
+		}
+	}
+
+	// 自定义判断
+	var calls int
+	_, err = ClientBuilder(session).
+		GET(addr).
+		Retry(RetryPolicy{Attempts: 5, Backoff: time.Millisecond, Retryable: func(_ *http.Response, err error) bool {
+			calls++
+			return calls < 2
+		}}).
+		Do()
+	if !errors.As(err, &e) || e.Attempts() != 2 {
+		t.Fatalf("expected 2 attempts, got %v", err)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.5}
+	for attempt, expected := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		expected *= time.Millisecond
+		for i := 0; i < 20; i++ {
+			delay := policy.backoff(attempt + 1)
+			if delay < expected/2 || delay > expected*3/2 {
+				t.Fatalf("attempt %d: delay %v out of range %v", attempt+1, delay, expected)
+			}
+		}
+	}
+
+	date := time.Now().Add(3 * time.Second).UTC().Format(http.TimeFormat)
+	after, ok := retryAfter(&http.Response{Header: http.Header{"Retry-After": {date}}})
+	if !ok || after <= time.Second || after > 3*time.Second {
+		t.Fatalf("unexpected Retry-After: %v", after)
+	}
+	if _, ok = retryAfter(&http.Response{Header: http.Header{"Retry-After": {strconv.Itoa(-1)}}}); ok {
+		t.Fatal("negative Retry-After accepted")
+	}
+}
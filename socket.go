@@ -3,7 +3,6 @@ package emit
 import (
 	"context"
 	"errors"
-	"fmt"
 	"github.com/RomiChan/websocket"
 	"golang.org/x/net/proxy"
 	"net"
@@ -26,6 +25,7 @@ type ConnBuilder struct {
 
 	session *Session
 	option  *ConnectOption
+	params  map[string]string
 }
 
 func SocketBuilder(session *Session) *ConnBuilder {
@@ -81,7 +81,7 @@ func (conn *ConnBuilder) Query(key, value string) *ConnBuilder {
 	if key == "" {
 		return conn
 	}
-	conn.query = append(conn.query, fmt.Sprintf("%s=%s", key, value))
+	conn.query = append(conn.query, queryPair(key, value))
 	return conn
 }
 
@@ -115,19 +115,30 @@ func (conn *ConnBuilder) Do() (*websocket.Conn, *http.Response, error) {
 		return nil, nil, Error{-1, "Do", "", errors.New("url cannot be empty, please execute func URL(url string)")}
 	}
 
-	query := ""
-	if len(conn.query) > 0 {
-		var slice []string
-		for _, value := range conn.query {
-			slice = append(slice, value)
-		}
-		query = "?" + strings.Join(slice, "&")
+	u, err := composeURL(conn.session, conn.url, conn.params, conn.query)
+	if err != nil {
+		return nil, nil, Error{-1, "Do", "", err}
+	}
+
+	// 基础地址为 http(s) 时转换为 ws(s)
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
 	}
 
 	h := http.Header{}
 	for k, v := range conn.headers {
 		h.Add(k, v)
 	}
+	if conn.session != nil {
+		for k, v := range conn.session.headers {
+			if h.Get(k) == "" {
+				h.Set(k, v)
+			}
+		}
+	}
 
 	var dialer *websocket.Dialer
 	if conn.session != nil {
@@ -144,7 +155,7 @@ func (conn *ConnBuilder) Do() (*websocket.Conn, *http.Response, error) {
 		dialer.Jar = conn.jar
 	}
 
-	c, response, err := dialer.Dial(conn.url+query, h)
+	c, response, err := dialer.Dial(u.String(), h)
 	if err != nil {
 		return c, response, err
 	}
@@ -0,0 +1,170 @@
+package emit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type SSEEvent struct {
+	Id    string
+	Event string
+	Data  string
+	// 服务端下发的重连间隔，未下发时为0
+	Retry time.Duration
+}
+
+// 按 WHATWG 规范解析 text/event-stream：
+// 支持 event、id、retry、多行 data、注释、CRLF / CR / LF 换行以及开头的 BOM
+type SSEReader struct {
+	scanner *bufio.Scanner
+	lastId  string
+	retry   time.Duration
+	bom     bool
+}
+
+// 单行最大长度，超出时 Next 返回 bufio.ErrTooLong
+const SSEMaxLineSize = 1024 * 1024
+
+func NewSSEReader(reader io.Reader) *SSEReader {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 4096), SSEMaxLineSize)
+	scanner.Split(scanSSELines)
+	return &SSEReader{scanner: scanner}
+}
+
+// 修改单行最大长度，需在第一次 Next 之前调用
+func (r *SSEReader) MaxLineSize(size int) *SSEReader {
+	r.scanner.Buffer(make([]byte, 0, 4096), size)
+	return r
+}
+
+// 最近一次的 id，用于重连时的 Last-Event-ID
+func (r *SSEReader) LastEventId() string {
+	return r.lastId
+}
+
+func (r *SSEReader) Retry() time.Duration {
+	return r.retry
+}
+
+// 读取下一个事件，流结束时返回 io.EOF；末尾未以空行结束的事件按规范丢弃
+func (r *SSEReader) Next() (*SSEEvent, error) {
+	var (
+		data    strings.Builder
+		hasData bool
+		event   string
+		retry   time.Duration
+	)
+
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if !r.bom {
+			r.bom = true
+			line = strings.TrimPrefix(line, "\uFEFF")
+		}
+
+		if line == "" {
+			if !hasData {
+				event = ""
+				continue
+			}
+
+			value := data.String()
+			return &SSEEvent{
+				Id:    r.lastId,
+				Event: event,
+				Data:  strings.TrimSuffix(value, "\n"),
+				Retry: retry,
+			}, nil
+		}
+
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			hasData = true
+			data.WriteString(value)
+			data.WriteByte('\n')
+		case "id":
+			if !strings.ContainsRune(value, 0) {
+				r.lastId = value
+			}
+		case "retry":
+			if ms, err := strconv.ParseUint(value, 10, 63); err == nil {
+				r.retry = time.Duration(ms) * time.Millisecond
+				retry = r.retry
+			}
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (event SSEEvent) Unmarshal(v interface{}) error {
+	return json.Unmarshal([]byte(event.Data), v)
+}
+
+// 逐个回调事件，回调返回false时停止；结束后关闭 body
+func ReadSSE(response *http.Response, funcCall func(event SSEEvent) bool) error {
+	defer response.Body.Close()
+	reader := NewSSEReader(response.Body)
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !funcCall(*event) {
+			return nil
+		}
+	}
+}
+
+// CRLF、CR、LF 均视为换行
+func scanSSELines(data []byte, eof bool) (advance int, token []byte, err error) {
+	if eof && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		// \r 在末尾，需要确认后面是否跟着 \n
+		if eof {
+			return i + 1, data[:i], nil
+		}
+		return 0, nil, nil
+	}
+
+	if eof {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
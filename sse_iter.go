@@ -0,0 +1,47 @@
+//go:build go1.23
+
+package emit
+
+import (
+	"io"
+	"iter"
+)
+
+// 以迭代器方式读取事件，异常结束时最后一次迭代返回 (SSEEvent{}, err)
+func (r *SSEReader) All() iter.Seq2[SSEEvent, error] {
+	return func(yield func(SSEEvent, error) bool) {
+		for {
+			event, err := r.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(SSEEvent{}, err)
+				return
+			}
+			if !yield(*event, nil) {
+				return
+			}
+		}
+	}
+}
+
+// 以迭代器方式读取事件并自动重连，提前 break 会关闭连接
+func (es *EventSource) All() iter.Seq2[SSEEvent, error] {
+	return func(yield func(SSEEvent, error) bool) {
+		defer es.Close()
+		for {
+			event, err := es.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(SSEEvent{}, err)
+				return
+			}
+			if !yield(*event, nil) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package emit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSSEReader(t *testing.T) {
+	stream := "\uFEFF: comment\r\n" +
+		"event: message_start\r\n" +
+		"id: 1\r\n" +
+		"data: {\"a\":1}\r\n\r\n" +
+		"data:line1\rdata: line2\r\r" +
+		"retry: 3000\n" +
+		"id\n" +
+		"data\n\n" +
+		"event: ignored\n\n" +
+		"data: incomplete"
+
+	reader := NewSSEReader(strings.NewReader(stream))
+	var events []SSEEvent
+	for {
+		event, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, *event)
+	}
+
+	expected := []SSEEvent{
+		{Id: "1", Event: "message_start", Data: `{"a":1}`},
+		{Id: "1", Data: "line1\nline2"},
+		{Id: "", Data: "", Retry: 3 * time.Second},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Fatalf("event %d: %+v != %+v", i, events[i], expected[i])
+		}
+	}
+
+	if reader.Retry() != 3*time.Second || reader.LastEventId() != "" {
+		t.Fatalf("unexpected reader state: %v %q", reader.Retry(), reader.LastEventId())
+	}
+}
+
+func TestReadSSE(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, line := range []string{"data: a\n\n", "data: b\n\n", "data: [DONE]\n\n", "data: c\n\n"} {
+			_, _ = w.Write([]byte(line))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).GET(server.URL)
+		if ja3 {
+			builder.Ja3()
+		}
+
+		response, err := builder.DoC(Status(http.StatusOK), IsSTREAM)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var values []string
+		err = ReadSSE(response, func(event SSEEvent) bool {
+			if event.Data == "[DONE]" {
+				return false
+			}
+			values = append(values, event.Data)
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(values, ",") != "a,b" {
+			t.Fatalf("ja3=%v unexpected values: %v", ja3, values)
+		}
+	}
+}
+
+func TestEventSource(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		lastIds []string
+		count   int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		count++
+		n := count
+		lastIds = append(lastIds, r.Header.Get("Last-Event-ID"))
+		mu.Unlock()
+
+		if body, _ := io.ReadAll(r.Body); string(body) != "payload" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		switch n {
+		case 1:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("retry: 10\nid: 1\ndata: a\n\nid: 2\ndata: b\n\n"))
+		case 2:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("id: 3\ndata: c\n\n"))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	es, err := ClientBuilder(session).
+		POST(server.URL).
+		Buffer(strings.NewReader("payload")).
+		Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer es.Close()
+
+	var values []string
+	for {
+		event, err := es.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, event.Data)
+	}
+
+	if strings.Join(values, ",") != "a,b,c" {
+		t.Fatalf("unexpected values: %v", values)
+	}
+	if strings.Join(lastIds, ",") != ",2,3" {
+		t.Fatalf("unexpected Last-Event-ID: %v", lastIds)
+	}
+}
+
+func TestEventSourceClose(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	es, err := ClientBuilder(session).Context(ctx).GET(server.URL).Stream()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := es.Next()
+		done <- err
+	}()
+
+	select {
+	case err = <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Next blocked after context deadline")
+	}
+}
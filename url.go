@@ -0,0 +1,105 @@
+package emit
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// 设置 session 的基础地址，Builder / ConnBuilder 的相对路径基于该地址解析。
+// 基础地址的路径视为目录：https://api.example.com/v1 + users => https://api.example.com/v1/users
+func BaseURLHelper(base string) OptionHelper {
+	return func(_ string, _ bool, session *Session) error {
+		u, err := url.Parse(base)
+		if err != nil {
+			return err
+		}
+		if !strings.HasSuffix(u.Path, "/") {
+			u.Path += "/"
+			if u.RawPath != "" {
+				u.RawPath += "/"
+			}
+		}
+		session.base = u
+		return nil
+	}
+}
+
+// 路径参数，替换 url 中的 {name}
+func (c *Builder) Param(name, value string) *Builder {
+	if c.params == nil {
+		c.params = make(map[string]string)
+	}
+	c.params[name] = value
+	return c
+}
+
+// 批量添加查询参数，按 key 排序，同名参数保持原有顺序
+func (c *Builder) Queries(values url.Values) *Builder {
+	c.query = appendQuery(c.query, values)
+	return c
+}
+
+func (conn *ConnBuilder) Param(name, value string) *ConnBuilder {
+	if conn.params == nil {
+		conn.params = make(map[string]string)
+	}
+	conn.params[name] = value
+	return conn
+}
+
+func (conn *ConnBuilder) Queries(values url.Values) *ConnBuilder {
+	conn.query = appendQuery(conn.query, values)
+	return conn
+}
+
+func queryPair(key, value string) string {
+	return url.QueryEscape(key) + "=" + url.QueryEscape(value)
+}
+
+func appendQuery(query []string, values url.Values) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if k == "" {
+			continue
+		}
+		for _, v := range values[k] {
+			query = append(query, queryPair(k, v))
+		}
+	}
+	return query
+}
+
+// 组装最终地址：替换路径参数、基于 session 基础地址解析相对路径，并把查询参数追加到已有的查询之后
+func composeURL(session *Session, raw string, params map[string]string, query []string) (*url.URL, error) {
+	for name, value := range params {
+		raw = strings.ReplaceAll(raw, "{"+name+"}", url.PathEscape(value))
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if session != nil && session.base != nil && !u.IsAbs() {
+		// 以 / 开头的路径同样视为相对基础地址
+		if u.Host == "" && strings.HasPrefix(u.Path, "/") {
+			u.Path = strings.TrimPrefix(u.Path, "/")
+			u.RawPath = strings.TrimPrefix(u.RawPath, "/")
+		}
+		u = session.base.ResolveReference(u)
+	}
+
+	if len(query) > 0 {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += strings.Join(query, "&")
+	}
+	return u, nil
+}
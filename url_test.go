@@ -0,0 +1,108 @@
+package emit
+
+import (
+	"github.com/RomiChan/websocket"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestComposeURL(t *testing.T) {
+	session, err := NewSession("", false, nil, BaseURLHelper("https://api.example.com/v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []struct {
+		session  *Session
+		raw      string
+		params   map[string]string
+		query    []string
+		expected string
+	}{
+		{nil, "https://a.com/search", nil, []string{queryPair("q", "a&b c"), queryPair("q", "你好")},
+			"https://a.com/search?q=a%26b+c&q=%E4%BD%A0%E5%A5%BD"},
+		{nil, "https://a.com/search?sig=x%2Fy&b=1#top", nil, []string{queryPair("page", "2")},
+			"https://a.com/search?sig=x%2Fy&b=1&page=2#top"},
+		{nil, "https://a.com/users/{id}/repos/{name}", map[string]string{"id": "a/b", "name": "x y"}, nil,
+			"https://a.com/users/a%2Fb/repos/x%20y"},
+		{session, "users/{id}", map[string]string{"id": "7"}, []string{queryPair("full", "1")},
+			"https://api.example.com/v1/users/7?full=1"},
+		{session, "/users", nil, nil, "https://api.example.com/v1/users"},
+		{session, "https://other.com/x", nil, nil, "https://other.com/x"},
+	} {
+		u, err := composeURL(c.session, c.raw, c.params, c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.String() != c.expected {
+			t.Fatalf("%s: %s != %s", c.raw, u, c.expected)
+		}
+	}
+}
+
+func TestBuilderQuery(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"path":"` + r.URL.EscapedPath() + `","query":"` + r.URL.RawQuery + `"}`))
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil, BaseURLHelper(server.URL+"/api"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ja3 := range []bool{false, true} {
+		builder := ClientBuilder(session).
+			GET("users/{id}?from=x").
+			Param("id", "a b").
+			Query("q", "a&b=c").
+			Queries(url.Values{"tag": {"1", "2"}})
+		if ja3 {
+			builder.Ja3()
+		}
+
+		result, err := DoJSON[map[string]string](builder, Status(http.StatusOK))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result["path"] != "/api/users/a%20b" || result["query"] != "from=x&q=a%26b%3Dc&tag=1&tag=2" {
+			t.Fatalf("ja3=%v unexpected url: %v", ja3, result)
+		}
+	}
+}
+
+func TestConnBuilderQuery(t *testing.T) {
+	query := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query <- r.URL.EscapedPath() + "?" + r.URL.RawQuery
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		_ = c.Close()
+	}))
+	defer server.Close()
+
+	session, err := NewSession("", false, nil, BaseURLHelper(server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, _, err := SocketBuilder(session).
+		URL("/ws/{room}").
+		Param("room", "a b").
+		Query("token", "x+y").
+		DoS(http.StatusSwitchingProtocols)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Close()
+
+	if value := <-query; value != "/ws/a%20b?token=x%2By" {
+		t.Fatalf("unexpected url: %s", value)
+	}
+}